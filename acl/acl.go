@@ -0,0 +1,200 @@
+// Package acl decodes POSIX access control lists, which Linux stores in the
+// system.posix_acl_access and system.posix_acl_default extended attributes
+// of a file.
+package acl
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os/user"
+	"strconv"
+
+	"go-ls-commands/xattr"
+)
+
+// Names of the extended attributes holding the access ACL of a file and
+// the default ACL given to new files in a directory
+const (
+	AccessAttr  = "system.posix_acl_access"
+	DefaultAttr = "system.posix_acl_default"
+)
+
+// version is the only version of the attribute format
+const version = 2
+
+// Tag is the kind of an ACL entry
+type Tag uint16
+
+// Tags of ACL entries, as stored in the attributes
+const (
+	UserObj  Tag = 0x01
+	User     Tag = 0x02
+	GroupObj Tag = 0x04
+	Group    Tag = 0x08
+	Mask     Tag = 0x10
+	Other    Tag = 0x20
+)
+
+// Entry grants the permissions in Perm, a combination of 4 (read), 2
+// (write) and 1 (execute). ID is the user or group for User and Group
+// entries.
+type Entry struct {
+	Tag  Tag
+	Perm uint16
+	ID   uint32
+}
+
+// ACL is an access control list in the order it is stored
+type ACL []Entry
+
+// lookupUser and lookupGroup name users and groups, replaced in tests
+var (
+	lookupUser = func(id string) (string, error) {
+		u, err := user.LookupId(id)
+		if err != nil {
+			return "", err
+		}
+		return u.Username, nil
+	}
+	lookupGroup = func(id string) (string, error) {
+		g, err := user.LookupGroupId(id)
+		if err != nil {
+			return "", err
+		}
+		return g.Name, nil
+	}
+)
+
+// Parse decodes an ACL from the value of an ACL attribute: a little endian
+// 32 bit version followed by 8 byte entries of a 16 bit tag, 16 bit
+// permissions and 32 bit id
+func Parse(data []byte) (ACL, error) {
+	if len(data) < 4 || (len(data)-4)%8 != 0 {
+		return nil, fmt.Errorf("invalid ACL of %d bytes", len(data))
+	}
+	if v := binary.LittleEndian.Uint32(data); v != version {
+		return nil, fmt.Errorf("unsupported ACL version %d", v)
+	}
+
+	var acl ACL
+	for data = data[4:]; len(data) > 0; data = data[8:] {
+		e := Entry{
+			Tag:  Tag(binary.LittleEndian.Uint16(data)),
+			Perm: binary.LittleEndian.Uint16(data[2:]),
+			ID:   binary.LittleEndian.Uint32(data[4:]),
+		}
+		switch e.Tag {
+		case UserObj, User, GroupObj, Group, Mask, Other:
+		default:
+			return nil, fmt.Errorf("invalid ACL entry tag %#x", uint16(e.Tag))
+		}
+		acl = append(acl, e)
+	}
+	return acl, nil
+}
+
+// Extended reports whether an ACL grants more than the owner, group and
+// other permissions of the file mode already show
+func (a ACL) Extended() bool {
+	for _, e := range a {
+		if e.Tag != UserObj && e.Tag != GroupObj && e.Tag != Other {
+			return true
+		}
+	}
+	return false
+}
+
+// Lines formats the entries of an ACL as getfacl does, each preceded by
+// prefix, such as "user:alice:rw-". Entries limited by the mask are
+// followed by the permissions they effectively grant.
+func (a ACL) Lines(prefix string) []string {
+	mask, hasMask := uint16(7), false
+	for _, e := range a {
+		if e.Tag == Mask {
+			mask, hasMask = e.Perm, true
+		}
+	}
+
+	lines := make([]string, 0, len(a))
+	for _, e := range a {
+		var line string
+		switch e.Tag {
+		case UserObj:
+			line = "user::"
+		case User:
+			line = "user:" + name(lookupUser, e.ID) + ":"
+		case GroupObj:
+			line = "group::"
+		case Group:
+			line = "group:" + name(lookupGroup, e.ID) + ":"
+		case Mask:
+			line = "mask::"
+		case Other:
+			line = "other::"
+		}
+		line = prefix + line + permString(e.Perm)
+
+		limited := e.Tag == User || e.Tag == GroupObj || e.Tag == Group
+		if hasMask && limited && e.Perm&^mask != 0 {
+			line += "\t#effective:" + permString(e.Perm&mask)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// name returns the name of a user or group, or its number if it has none
+func name(lookup func(string) (string, error), id uint32) string {
+	s := strconv.FormatUint(uint64(id), 10)
+	if n, err := lookup(s); err == nil {
+		return n
+	}
+	return s
+}
+
+// permString formats permissions as rwx with dashes for those not granted
+func permString(perm uint16) string {
+	b := []byte("---")
+	if perm&4 != 0 {
+		b[0] = 'r'
+	}
+	if perm&2 != 0 {
+		b[1] = 'w'
+	}
+	if perm&1 != 0 {
+		b[2] = 'x'
+	}
+	return string(b)
+}
+
+// Read returns the access and default ACLs of a file, following symlinks.
+// Either is nil when the file has none or its file system has no ACLs.
+func Read(path string) (access, defaults ACL, err error) {
+	if access, err = readAttr(path, AccessAttr); err != nil {
+		return nil, nil, err
+	}
+	if defaults, err = readAttr(path, DefaultAttr); err != nil {
+		return nil, nil, err
+	}
+	return access, defaults, nil
+}
+
+// readAttr reads and decodes one ACL attribute
+func readAttr(path, attr string) (ACL, error) {
+	data, err := xattr.Get(path, attr)
+	if xattr.IsMissing(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// HasExtended reports whether a file has an ACL beyond its mode bits,
+// which ls marks with a + after the permissions. A default ACL on a
+// directory counts, as it does for GNU ls.
+func HasExtended(path string) bool {
+	access, defaults, err := Read(path)
+	return err == nil && (access.Extended() || len(defaults) > 0)
+}
@@ -0,0 +1,113 @@
+package acl
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// encode builds the attribute value of an ACL
+func encode(entries ...Entry) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, version)
+	for _, e := range entries {
+		data = binary.LittleEndian.AppendUint16(data, uint16(e.Tag))
+		data = binary.LittleEndian.AppendUint16(data, e.Perm)
+		data = binary.LittleEndian.AppendUint32(data, e.ID)
+	}
+	return data
+}
+
+// undefinedID is stored as the id of entries that have none
+const undefinedID = 0xffffffff
+
+// fakeNames names user 1000 alice and group 100 staff while a test runs
+func fakeNames(t *testing.T) {
+	origUser, origGroup := lookupUser, lookupGroup
+	t.Cleanup(func() { lookupUser, lookupGroup = origUser, origGroup })
+
+	names := func(known map[string]string) func(string) (string, error) {
+		return func(id string) (string, error) {
+			if name, ok := known[id]; ok {
+				return name, nil
+			}
+			return "", fmt.Errorf("unknown id %s", id)
+		}
+	}
+	lookupUser = names(map[string]string{"1000": "alice"})
+	lookupGroup = names(map[string]string{"100": "staff"})
+}
+
+func TestParse(t *testing.T) {
+	fakeNames(t)
+
+	tests := []struct {
+		name     string
+		entries  []Entry
+		extended bool
+		lines    string
+	}{
+		{
+			name:    "Trivial",
+			entries: []Entry{{UserObj, 6, undefinedID}, {GroupObj, 4, undefinedID}, {Other, 4, undefinedID}},
+			lines:   "user::rw- group::r-- other::r--",
+		},
+		{
+			name: "Named entries under a mask",
+			entries: []Entry{
+				{UserObj, 7, undefinedID}, {User, 7, 1000}, {User, 6, 1001},
+				{GroupObj, 5, undefinedID}, {Group, 2, 100}, {Mask, 5, undefinedID}, {Other, 0, undefinedID},
+			},
+			extended: true,
+			lines: "user::rwx user:alice:rwx\t#effective:r-x user:1001:rw-\t#effective:r-- " +
+				"group::r-x group:staff:-w-\t#effective:--- mask::r-x other::---",
+		},
+		{
+			name:     "Mask only",
+			entries:  []Entry{{UserObj, 6, undefinedID}, {GroupObj, 6, undefinedID}, {Mask, 7, undefinedID}, {Other, 4, undefinedID}},
+			extended: true,
+			lines:    "user::rw- group::rw- mask::rwx other::r--",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acl, err := Parse(encode(tt.entries...))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(acl) != len(tt.entries) {
+				t.Fatalf("Parse() returned %d entries, want %d", len(acl), len(tt.entries))
+			}
+			if acl.Extended() != tt.extended {
+				t.Errorf("Extended() = %v, want %v", acl.Extended(), tt.extended)
+			}
+			if got := strings.Join(acl.Lines(""), " "); got != tt.lines {
+				t.Errorf("Lines() = %q, want %q", got, tt.lines)
+			}
+		})
+	}
+
+	// Default ACLs are prefixed like getfacl prints them
+	acl, _ := Parse(encode(Entry{UserObj, 7, undefinedID}))
+	if got := acl.Lines("default:"); len(got) != 1 || got[0] != "default:user::rwx" {
+		t.Errorf("Lines(default:) = %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	valid := encode(Entry{UserObj, 6, undefinedID})
+	wrongVersion := encode(Entry{UserObj, 6, undefinedID})
+	wrongVersion[0] = 1
+
+	for name, data := range map[string][]byte{
+		"Empty":         nil,
+		"Truncated":     valid[:len(valid)-1],
+		"Wrong version": wrongVersion,
+		"Unknown tag":   encode(Entry{0x40, 6, undefinedID}),
+	} {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("Parse() of %s succeeded, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLinkHops bounds how many symlinks Stat follows inside an archive
+const maxLinkHops = 40
+
+// Header holds the ownership details an archive records for a member
+type Header struct {
+	Uid   int
+	Gid   int
+	Uname string
+	Gname string
+}
+
+// Owner returns the name of the member's owner, or its id if unnamed
+func (h *Header) Owner() string {
+	if h.Uname != "" {
+		return h.Uname
+	}
+	return strconv.Itoa(h.Uid)
+}
+
+// Group returns the name of the member's group, or its id if unnamed
+func (h *Header) Group() string {
+	if h.Gname != "" {
+		return h.Gname
+	}
+	return strconv.Itoa(h.Gid)
+}
+
+// fileInfo describes an archive member. Its Sys value is a *Header when
+// the archive records ownership, and nil otherwise.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	header  *Header
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{} {
+	if fi.header == nil {
+		return nil
+	}
+	return fi.header
+}
+
+// node is a member of the archive tree
+type node struct {
+	info     *fileInfo
+	target   string
+	children map[string]*node
+}
+
+// FS is a read-only file system built from the headers of an archive. It
+// describes members but does not hold their contents, so reading a file
+// fails.
+type FS struct {
+	root    *node
+	modTime time.Time
+}
+
+// IsArchive reports whether a file name has the extension of a supported
+// archive format
+func IsArchive(name string) bool {
+	return format(name) != ""
+}
+
+// format returns the archive format of a file name, judged by its extension
+func format(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar.bz2"), strings.HasSuffix(lower, ".tbz2"), strings.HasSuffix(lower, ".tbz"):
+		return "tar.bz2"
+	case strings.HasSuffix(lower, ".zip"), strings.HasSuffix(lower, ".jar"):
+		return "zip"
+	}
+	return ""
+}
+
+// Split finds the archive a path starts with, so that build.zip/inner/dir
+// splits into build.zip and inner/dir. It reports false when no leading
+// part of the path is an archive file.
+func Split(p string) (string, string, bool) {
+	parts := strings.Split(p, "/")
+	for i := 1; i <= len(parts); i++ {
+		prefix := strings.Join(parts[:i], "/")
+		if !IsArchive(prefix) {
+			continue
+		}
+		if info, err := os.Stat(prefix); err == nil && info.Mode().IsRegular() {
+			return prefix, strings.Join(parts[i:], "/"), true
+		}
+	}
+	return "", "", false
+}
+
+// Open reads the archive at name, choosing the format by its extension
+func Open(name string) (*FS, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	switch format(name) {
+	case "tar":
+		return ReadTar(f, info.ModTime())
+	case "tar.gz":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		defer gz.Close()
+		return ReadTar(gz, info.ModTime())
+	case "tar.bz2":
+		return ReadTar(bzip2.NewReader(f), info.ModTime())
+	case "zip":
+		zr, err := zip.NewReader(f, info.Size())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return ReadZip(zr, info.ModTime())
+	}
+	return nil, fmt.Errorf("%s: unsupported archive format", name)
+}
+
+// ReadTar builds a file system from the headers of a tar stream. Parent
+// directories missing from the archive get the time modTime.
+func ReadTar(r io.Reader, modTime time.Time) (*FS, error) {
+	fsys := newFS(modTime)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fsys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		info := &fileInfo{
+			size:    hdr.Size,
+			mode:    hdr.FileInfo().Mode(),
+			modTime: hdr.ModTime,
+			header:  &Header{Uid: hdr.Uid, Gid: hdr.Gid, Uname: hdr.Uname, Gname: hdr.Gname},
+		}
+		target := ""
+		if hdr.Typeflag == tar.TypeSymlink {
+			target = hdr.Linkname
+			info.size = int64(len(hdr.Linkname))
+		}
+		fsys.add(hdr.Name, info, target)
+	}
+}
+
+// ReadZip builds a file system from the central directory of a zip
+// archive. Zip archives record no owners, and symlink targets are read from
+// the content of the link members.
+func ReadZip(zr *zip.Reader, modTime time.Time) (*FS, error) {
+	fsys := newFS(modTime)
+	for _, f := range zr.File {
+		info := &fileInfo{
+			size:    int64(f.UncompressedSize64),
+			mode:    f.Mode(),
+			modTime: f.Modified,
+		}
+
+		target := ""
+		if info.mode&fs.ModeSymlink != 0 {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			data, err := io.ReadAll(io.LimitReader(rc, 4096))
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			target = string(data)
+		}
+		fsys.add(f.Name, info, target)
+	}
+	return fsys, nil
+}
+
+// newFS creates a file system holding only its root directory
+func newFS(modTime time.Time) *FS {
+	return &FS{
+		root:    &node{info: &fileInfo{name: ".", mode: fs.ModeDir | 0755, modTime: modTime}, children: map[string]*node{}},
+		modTime: modTime,
+	}
+}
+
+// add inserts a member, creating any parent directories it needs
+func (f *FS) add(name string, info *fileInfo, target string) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		// An explicit entry for the root carries its permissions
+		if info.IsDir() {
+			info.name = "."
+			f.root.info = info
+		}
+		return
+	}
+
+	parent := f.root
+	parts := strings.Split(name, "/")
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := parent.children[part]
+		if !ok || !child.info.IsDir() {
+			child = &node{
+				info:     &fileInfo{name: part, mode: fs.ModeDir | 0755, modTime: f.modTime},
+				children: map[string]*node{},
+			}
+			parent.children[part] = child
+		}
+		parent = child
+	}
+
+	base := parts[len(parts)-1]
+	info.name = base
+	n := &node{info: info, target: target}
+	if info.IsDir() {
+		n.children = map[string]*node{}
+		// Keep members already added below a directory listed late
+		if existing, ok := parent.children[base]; ok && existing.children != nil {
+			n.children = existing.children
+		}
+	}
+	parent.children[base] = n
+}
+
+// lookup finds the node for a valid io/fs path without following symlinks
+func (f *FS) lookup(op, name string) (*node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	n := f.root
+	if name == "." {
+		return n, nil
+	}
+	for _, part := range strings.Split(name, "/") {
+		child, ok := n.children[part]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// resolve finds the node for a path, following symlinks within the archive
+func (f *FS) resolve(op, name string) (*node, error) {
+	for hops := 0; hops < maxLinkHops; hops++ {
+		n, err := f.lookup(op, name)
+		if err != nil {
+			return nil, err
+		}
+		if n.info.mode&fs.ModeSymlink == 0 {
+			return n, nil
+		}
+
+		target := n.target
+		if !strings.HasPrefix(target, "/") {
+			target = path.Join(path.Dir(name), target)
+		}
+		name = strings.TrimPrefix(path.Clean("/"+target), "/")
+		if name == "" {
+			name = "."
+		}
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: errors.New("too many levels of symbolic links")}
+}
+
+// Open opens a member. Directories can be read, while regular files only
+// support Stat since the archive contents are not kept.
+func (f *FS) Open(name string) (fs.File, error) {
+	n, err := f.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &openFile{node: n, name: name}, nil
+}
+
+// Stat describes a member, following symlinks
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	n, err := f.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return n.info, nil
+}
+
+// Lstat describes a member without following symlinks
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	n, err := f.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return n.info, nil
+}
+
+// ReadLink returns the target of a symlink member
+func (f *FS) ReadLink(name string) (string, error) {
+	n, err := f.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if n.info.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return n.target, nil
+}
+
+// ReadDir lists a directory member sorted by name
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := f.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.children == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return n.entries(), nil
+}
+
+// entries returns the children of a directory node sorted by name
+func (n *node) entries() []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for _, child := range n.children {
+		entries = append(entries, fs.FileInfoToDirEntry(child.info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+// openFile is an open member of the archive
+type openFile struct {
+	node    *node
+	name    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (o *openFile) Stat() (fs.FileInfo, error) { return o.node.info, nil }
+func (o *openFile) Close() error               { return nil }
+
+func (o *openFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: o.name, Err: errors.ErrUnsupported}
+}
+
+// ReadDir reads the next n entries of a directory, or all remaining ones
+// when n is zero or less
+func (o *openFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	if o.node.children == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: o.name, Err: errors.New("not a directory")}
+	}
+	if o.entries == nil {
+		o.entries = o.node.entries()
+	}
+
+	remaining := o.entries[o.offset:]
+	if count <= 0 {
+		o.offset = len(o.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	o.offset += count
+	return remaining[:count], nil
+}
@@ -0,0 +1,256 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+// buildTar writes a tar archive with a directory, files with special bits,
+// a symlink and a member whose parent directory has no entry of its own
+func buildTar(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	headers := []*tar.Header{
+		{Name: "./", Typeflag: tar.TypeDir, Mode: 0750, ModTime: testTime},
+		{Name: "./bin/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: testTime, Uname: "alice", Gname: "staff"},
+		{Name: "./bin/tool", Typeflag: tar.TypeReg, Mode: 04755, Size: 4, ModTime: testTime, Uid: 1000, Gid: 1000},
+		{Name: "./bin/latest", Typeflag: tar.TypeSymlink, Linkname: "tool", Mode: 0777, ModTime: testTime},
+		{Name: "./share/doc/README", Typeflag: tar.TypeReg, Mode: 0644, Size: 2, ModTime: testTime, Uname: "bob"},
+		{Name: "./loop", Typeflag: tar.TypeSymlink, Linkname: "loop", Mode: 0777, ModTime: testTime},
+		{Name: "./docs", Typeflag: tar.TypeSymlink, Linkname: "share/doc", Mode: 0777, ModTime: testTime},
+	}
+	for _, hdr := range headers {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		_, _ = tw.Write(bytes.Repeat([]byte("x"), int(hdr.Size)))
+	}
+	_ = tw.Close()
+	return buf.Bytes()
+}
+
+func TestReadTar(t *testing.T) {
+	fsys, err := ReadTar(bytes.NewReader(buildTar(t)), testTime.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ReadTar() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		mode    fs.FileMode
+		size    int64
+		owner   string
+		group   string
+		modTime time.Time
+	}{
+		{".", fs.ModeDir | 0750, 0, "0", "0", testTime},
+		{"bin", fs.ModeDir | 0755, 0, "alice", "staff", testTime},
+		{"bin/tool", fs.ModeSetuid | 0755, 4, "1000", "1000", testTime},
+		{"bin/latest", fs.ModeSymlink | 0777, 4, "0", "0", testTime},
+		{"share/doc/README", 0644, 2, "bob", "0", testTime},
+	}
+
+	for _, tt := range tests {
+		info, err := fsys.Lstat(tt.name)
+		if err != nil {
+			t.Errorf("Lstat(%q) error = %v", tt.name, err)
+			continue
+		}
+		header, _ := info.Sys().(*Header)
+		if info.Mode() != tt.mode || info.Size() != tt.size || !info.ModTime().Equal(tt.modTime) ||
+			header == nil || header.Owner() != tt.owner || header.Group() != tt.group {
+			t.Errorf("Lstat(%q) = %v %d %v %+v", tt.name, info.Mode(), info.Size(), info.ModTime(), header)
+		}
+	}
+
+	// Directories missing from the archive are created with the archive's time
+	info, err := fsys.Stat("share")
+	if err != nil || !info.IsDir() || !info.ModTime().Equal(testTime.Add(time.Hour)) || info.Sys() != nil {
+		t.Errorf("Stat(share) = %v, %v", info, err)
+	}
+
+	target, err := fsys.ReadLink("bin/latest")
+	if err != nil || target != "tool" {
+		t.Errorf("ReadLink(bin/latest) = %q, %v", target, err)
+	}
+	if _, err := fsys.ReadLink("bin/tool"); err == nil {
+		t.Errorf("ReadLink(bin/tool) expected an error")
+	}
+
+	// Stat follows symlinks within the archive
+	if info, err := fsys.Stat("bin/latest"); err != nil || info.Name() != "tool" {
+		t.Errorf("Stat(bin/latest) = %v, %v", info, err)
+	}
+	if info, err := fsys.Stat("docs"); err != nil || !info.IsDir() {
+		t.Errorf("Stat(docs) = %v, %v", info, err)
+	}
+	if _, err := fsys.Stat("loop"); err == nil {
+		t.Errorf("Stat(loop) expected an error")
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatalf("ReadDir(.) error = %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, " ") != "bin docs loop share" {
+		t.Errorf("ReadDir(.) = %v", names)
+	}
+
+	if _, err := fsys.Open("missing"); err == nil {
+		t.Errorf("Open(missing) expected an error")
+	}
+	if _, err := fsys.Open("../x"); err == nil {
+		t.Errorf("Open(../x) expected an error")
+	}
+}
+
+func TestOpenFileReadDir(t *testing.T) {
+	fsys, _ := ReadTar(bytes.NewReader(buildTar(t)), testTime)
+
+	f, err := fsys.Open(".")
+	if err != nil {
+		t.Fatalf("Open(.) error = %v", err)
+	}
+	dir := f.(fs.ReadDirFile)
+
+	first, err := dir.ReadDir(3)
+	if err != nil || len(first) != 3 {
+		t.Fatalf("ReadDir(3) = %d entries, %v", len(first), err)
+	}
+	rest, err := dir.ReadDir(3)
+	if err != nil || len(rest) != 1 {
+		t.Fatalf("ReadDir(3) = %d entries, %v", len(rest), err)
+	}
+	if _, err := dir.ReadDir(3); err == nil {
+		t.Errorf("ReadDir(3) at the end expected io.EOF")
+	}
+
+	file, _ := fsys.Open("bin/tool")
+	if _, err := file.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Read() of a member expected an error")
+	}
+}
+
+func TestReadZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	file := &zip.FileHeader{Name: "pkg/run.sh", Modified: testTime}
+	file.SetMode(0755)
+	w, _ := zw.CreateHeader(file)
+	_, _ = w.Write([]byte("echo"))
+
+	link := &zip.FileHeader{Name: "pkg/current", Modified: testTime}
+	link.SetMode(fs.ModeSymlink | 0777)
+	w, _ = zw.CreateHeader(link)
+	_, _ = w.Write([]byte("run.sh"))
+	_ = zw.Close()
+
+	zr, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	fsys, err := ReadZip(zr, testTime)
+	if err != nil {
+		t.Fatalf("ReadZip() error = %v", err)
+	}
+
+	info, err := fsys.Lstat("pkg/run.sh")
+	if err != nil || info.Mode() != 0755 || info.Size() != 4 || info.Sys() != nil {
+		t.Errorf("Lstat(pkg/run.sh) = %v, %v", info, err)
+	}
+	target, err := fsys.ReadLink("pkg/current")
+	if err != nil || target != "run.sh" {
+		t.Errorf("ReadLink(pkg/current) = %q, %v", target, err)
+	}
+}
+
+func TestOpenAndSplit(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, _ = gz.Write(buildTar(t))
+	_ = gz.Close()
+	archive := filepath.Join(tmpDir, "build.tar.gz")
+
+	// compress/bzip2 can only decompress, so the bzip2 archive is the
+	// output of buildTar compressed with bzip2 -9 and checked in
+	tarBzip2, err := os.ReadFile("testdata/build.tar.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archives := []struct {
+		name string
+		data []byte
+	}{
+		{"build.tar", buildTar(t)},
+		{"build.tar.gz", buf.Bytes()},
+		{"build.tar.bz2", tarBzip2},
+	}
+	for _, a := range archives {
+		path := filepath.Join(tmpDir, a.name)
+		_ = os.WriteFile(path, a.data, 0644)
+
+		fsys, err := Open(path)
+		if err != nil {
+			t.Errorf("Open(%s) error = %v", a.name, err)
+			continue
+		}
+		if info, err := fsys.Lstat("bin/tool"); err != nil || info.Mode() != fs.ModeSetuid|0755 || info.Size() != 4 {
+			t.Errorf("Lstat(bin/tool) in %s = %v, %v", a.name, info, err)
+		}
+		if target, err := fsys.ReadLink("bin/latest"); err != nil || target != "tool" {
+			t.Errorf("ReadLink(bin/latest) in %s = %q, %v", a.name, target, err)
+		}
+	}
+
+	_ = os.WriteFile(filepath.Join(tmpDir, "broken.zip"), []byte("not a zip"), 0644)
+	if _, err := Open(filepath.Join(tmpDir, "broken.zip")); err == nil {
+		t.Errorf("Open(broken.zip) expected an error")
+	}
+
+	tests := []struct {
+		path    string
+		archive string
+		inner   string
+		ok      bool
+	}{
+		{archive, archive, "", true},
+		{archive + "/bin/tool", archive, "bin/tool", true},
+		{filepath.Join(tmpDir, "missing.zip/dir"), "", "", false},
+		{tmpDir, "", "", false},
+	}
+	for _, tt := range tests {
+		archive, inner, ok := Split(tt.path)
+		if archive != tt.archive || inner != tt.inner || ok != tt.ok {
+			t.Errorf("Split(%q) = %q, %q, %v", tt.path, archive, inner, ok)
+		}
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	tests := map[string]bool{
+		"a.tar": true, "a.tar.gz": true, "a.TGZ": true, "a.tar.bz2": true, "a.tbz2": true,
+		"a.zip": true, "a.jar": true, "a.gz": false, "a.txt": false, "tar": false,
+	}
+	for name, expected := range tests {
+		if got := IsArchive(name); got != expected {
+			t.Errorf("IsArchive(%q) = %v, want %v", name, got, expected)
+		}
+	}
+}
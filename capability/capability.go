@@ -0,0 +1,153 @@
+// Package capability decodes the Linux file capabilities stored in the
+// security.capability extended attribute, which grant a program some root
+// privileges when it runs.
+package capability
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"go-ls-commands/xattr"
+)
+
+// Attr is the extended attribute holding the capabilities of a file
+const Attr = "security.capability"
+
+// Revisions of the attribute format, kept in the top byte of its first
+// word, and the flag in its lowest bit that makes permitted capabilities
+// effective when the program starts
+const (
+	revisionMask = 0xff000000
+	revision1    = 0x01000000
+	revision2    = 0x02000000
+	revision3    = 0x03000000
+	effective    = 0x000001
+)
+
+// names holds the name of each capability by number, as libcap prints it
+var names = []string{
+	"cap_chown", "cap_dac_override", "cap_dac_read_search", "cap_fowner",
+	"cap_fsetid", "cap_kill", "cap_setgid", "cap_setuid", "cap_setpcap",
+	"cap_linux_immutable", "cap_net_bind_service", "cap_net_broadcast",
+	"cap_net_admin", "cap_net_raw", "cap_ipc_lock", "cap_ipc_owner",
+	"cap_sys_module", "cap_sys_rawio", "cap_sys_chroot", "cap_sys_ptrace",
+	"cap_sys_pacct", "cap_sys_admin", "cap_sys_boot", "cap_sys_nice",
+	"cap_sys_resource", "cap_sys_time", "cap_sys_tty_config", "cap_mknod",
+	"cap_lease", "cap_audit_write", "cap_audit_control", "cap_setfcap",
+	"cap_mac_override", "cap_mac_admin", "cap_syslog", "cap_wake_alarm",
+	"cap_block_suspend", "cap_audit_read", "cap_perfmon", "cap_bpf",
+	"cap_checkpoint_restore",
+}
+
+// Set holds the capabilities of a file as bit masks indexed by number
+type Set struct {
+	Permitted   uint64
+	Inheritable uint64
+	Effective   bool
+
+	// RootID is the user namespace root the capabilities apply to, for
+	// revision 3 attributes
+	RootID uint32
+}
+
+// Parse decodes the value of a security.capability attribute: a little
+// endian word of revision and flags followed by permitted and inheritable
+// words, two of each from revision 2 on, and a root id in revision 3
+func Parse(data []byte) (Set, error) {
+	if len(data) < 4 {
+		return Set{}, fmt.Errorf("invalid capabilities of %d bytes", len(data))
+	}
+	magic := binary.LittleEndian.Uint32(data)
+
+	var words, size int
+	switch magic & revisionMask {
+	case revision1:
+		words, size = 1, 12
+	case revision2:
+		words, size = 2, 20
+	case revision3:
+		words, size = 2, 24
+	default:
+		return Set{}, fmt.Errorf("unsupported capability revision %#x", magic&revisionMask)
+	}
+	if len(data) != size {
+		return Set{}, fmt.Errorf("invalid capabilities of %d bytes", len(data))
+	}
+
+	set := Set{Effective: magic&effective != 0}
+	for i := 0; i < words; i++ {
+		set.Permitted |= uint64(binary.LittleEndian.Uint32(data[4+8*i:])) << (32 * i)
+		set.Inheritable |= uint64(binary.LittleEndian.Uint32(data[8+8*i:])) << (32 * i)
+	}
+	if magic&revisionMask == revision3 {
+		set.RootID = binary.LittleEndian.Uint32(data[20:])
+	}
+	return set, nil
+}
+
+// Name returns the name of a capability by number
+func Name(n int) string {
+	if n < len(names) {
+		return names[n]
+	}
+	return fmt.Sprintf("cap_%d", n)
+}
+
+// String formats the capabilities as libcap did before it switched to =,
+// grouping those with the same flags, such as
+// "cap_net_admin,cap_net_raw+ep cap_kill+i". It is empty for no capabilities.
+func (s Set) String() string {
+	var groups []string
+	members := map[string][]string{}
+	for n := 0; n < 64; n++ {
+		flags := s.flags(n)
+		if flags == "" {
+			continue
+		}
+		if _, ok := members[flags]; !ok {
+			groups = append(groups, flags)
+		}
+		members[flags] = append(members[flags], Name(n))
+	}
+
+	parts := make([]string, 0, len(groups))
+	for _, flags := range groups {
+		parts = append(parts, strings.Join(members[flags], ",")+"+"+flags)
+	}
+	if s.RootID != 0 && len(parts) > 0 {
+		parts = append(parts, fmt.Sprintf("[rootid=%d]", s.RootID))
+	}
+	return strings.Join(parts, " ")
+}
+
+// flags returns the flags of one capability in libcap order, e, i and p
+func (s Set) flags(n int) string {
+	bit := uint64(1) << n
+	permitted, inheritable := s.Permitted&bit != 0, s.Inheritable&bit != 0
+
+	var flags string
+	if s.Effective && (permitted || inheritable) {
+		flags += "e"
+	}
+	if inheritable {
+		flags += "i"
+	}
+	if permitted {
+		flags += "p"
+	}
+	return flags
+}
+
+// Read returns the capabilities of a file, which are empty when it has
+// none or its file system does not record them. Symlinks have none.
+func Read(path string) (Set, error) {
+	data, err := xattr.LGet(path, Attr)
+	if xattr.IsMissing(err) {
+		return Set{}, nil
+	}
+	if err != nil {
+		return Set{}, err
+	}
+	return Parse(data)
+}
@@ -0,0 +1,75 @@
+package capability
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// encode builds an attribute value from its words
+func encode(words ...uint32) []byte {
+	var data []byte
+	for _, w := range words {
+		data = binary.LittleEndian.AppendUint32(data, w)
+	}
+	return data
+}
+
+// bit returns the mask of a capability by number, within its word
+func bit(n int) uint32 {
+	return 1 << (n % 32)
+}
+
+func TestParse(t *testing.T) {
+	const (
+		chown          = 0
+		kill           = 5
+		netBindService = 10
+		netAdmin       = 12
+		netRaw         = 13
+		bpf            = 39
+	)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"Revision 1", encode(revision1|effective, bit(netBindService), 0), "cap_net_bind_service+ep"},
+		{"Revision 2", encode(revision2|effective, bit(netBindService), 0, 0, 0), "cap_net_bind_service+ep"},
+		{"Not effective", encode(revision2, bit(chown), 0, 0, 0), "cap_chown+p"},
+		{
+			"Grouped by flags",
+			encode(revision2|effective, bit(netAdmin)|bit(netRaw), bit(kill), 0, 0),
+			"cap_kill+ei cap_net_admin,cap_net_raw+ep",
+		},
+		{"Upper word", encode(revision2|effective, 0, 0, bit(bpf), bit(bpf)), "cap_bpf+eip"},
+		{"Unnamed", encode(revision2, 0, 0, bit(60), 0), "cap_60+p"},
+		{"Revision 3", encode(revision3|effective, bit(netRaw), 0, 0, 0, 1000), "cap_net_raw+ep [rootid=1000]"},
+		{"Empty", encode(revision2, 0, 0, 0, 0), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := Parse(tt.data)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := set.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"Empty":            nil,
+		"Unknown revision": encode(0x04000000, 0, 0, 0, 0),
+		"Wrong size":       encode(revision2|effective, 1, 0),
+		"Revision 3 short": encode(revision3, 0, 0, 0, 0),
+	} {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("Parse() of %s succeeded, want an error", name)
+		}
+	}
+}
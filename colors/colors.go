@@ -32,55 +32,51 @@ const (
 	Reset = "\033[0m" // Reset color
 )
 
-// GetFileColor determines the color for a file based on its type using the colorMap.
-func GetFileColor(file os.FileInfo) string {
-	if file.IsDir() {
-		if color, ok := colorMap["di"]; ok {
-			return "\033[" + color + "m"
-		}
-		return "\033[34m" // Default to blue if not found
-	}
-
-	// Check for symbolic links (symlinks)
-	if file.Mode()&os.ModeSymlink != 0 {
-		if color, ok := colorMap["ln"]; ok {
-			return "\033[" + color + "m"
-		}
-		return "\033[0m" // Default to light cyan if not found
-	}
-
-	// Check for executable files
-	if file.Mode().Perm()&0o111 != 0 {
-		if color, ok := colorMap["ex"]; ok {
-			return "\033[" + color + "m"
-		}
-		return "\033[32m" // Default to green if executable color is not found
-	}
+// defaultColors holds the color of each file type missing from LS_COLORS
+var defaultColors = map[string]string{
+	"di": "\033[34m", // Blue
+	"ln": "\033[0m",  // Plain
+	"ex": "\033[32m", // Green
+	"bd": "\033[33m", // Yellow
+	"cd": "\033[33m", // Yellow
+	"pi": "\033[31m", // Red
+}
 
-	// Check for block devices
-	if file.Mode()&os.ModeDevice != 0 && file.Mode()&os.ModeCharDevice == 0 {
-		if color, ok := colorMap["bd"]; ok {
-			return "\033[" + color + "m"
-		}
-		return "\033[33m" // Default to yellow for block devices
+// FileType returns the LS_COLORS key for the type of a file: di for
+// directories, ln for symlinks, ex for executables, bd and cd for block and
+// character devices, pi for named pipes, so for sockets and fi for anything
+// else. Checks are made in that order, so an executable device is ex.
+func FileType(file os.FileInfo) string {
+	switch mode := file.Mode(); {
+	case file.IsDir():
+		return "di"
+	case mode&os.ModeSymlink != 0:
+		return "ln"
+	case mode.Perm()&0o111 != 0:
+		return "ex"
+	case mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0:
+		return "bd"
+	case mode&os.ModeCharDevice != 0:
+		return "cd"
+	case mode&os.ModeNamedPipe != 0:
+		return "pi"
+	case mode&os.ModeSocket != 0:
+		return "so"
 	}
+	return "fi"
+}
 
-	// Check for character devices
-	if file.Mode()&os.ModeCharDevice != 0 {
-		if color, ok := colorMap["cd"]; ok {
-			return "\033[" + color + "m"
-		}
-		return "\033[33m" // Default to yellow for character devices
+// GetFileColor determines the color for a file based on its type using the colorMap.
+func GetFileColor(file os.FileInfo) string {
+	fileType := FileType(file)
+	defaultColor, ok := defaultColors[fileType]
+	if !ok {
+		// Fallback to reset if no specific color is found
+		return Reset
 	}
 
-	// Check for named pipes (e.g., FIFO files)
-	if file.Mode()&os.ModeNamedPipe != 0 {
-		if color, ok := colorMap["pi"]; ok {
-			return "\033[" + color + "m"
-		}
-		return "\033[31m" // Default to red if not found
+	if color, ok := colorMap[fileType]; ok {
+		return "\033[" + color + "m"
 	}
-
-	// Fallback to reset if no specific color is found
-	return Reset
+	return defaultColor
 }
@@ -127,3 +127,29 @@ func TestColors(t *testing.T) {
 		})
 	}
 }
+
+func TestFileType(t *testing.T) {
+	tests := []struct {
+		name string
+		file mockFileInfo
+		want string
+	}{
+		{"Directory", mockFileInfo{mode: os.ModeDir | 0755, isDir: true}, "di"},
+		{"Symlink", mockFileInfo{mode: os.ModeSymlink | 0777}, "ln"},
+		{"Executable", mockFileInfo{mode: 0755}, "ex"},
+		{"Block device", mockFileInfo{mode: os.ModeDevice | 0660}, "bd"},
+		{"Character device", mockFileInfo{mode: os.ModeDevice | os.ModeCharDevice | 0666}, "cd"},
+		{"Named pipe", mockFileInfo{mode: os.ModeNamedPipe | 0644}, "pi"},
+		{"Socket", mockFileInfo{mode: os.ModeSocket | 0644}, "so"},
+		{"Regular file", mockFileInfo{mode: 0644}, "fi"},
+		{"Executable device", mockFileInfo{mode: os.ModeDevice | 0755}, "ex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FileType(tt.file); got != tt.want {
+				t.Errorf("FileType() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Package dirsize adds up the size of everything below a directory, the way
+// du does: concurrently, counting hard linked files once and stopping as
+// soon as its context is cancelled.
+package dirsize
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// FS is a file system that can describe symlinks without following them.
+// Paths are joined with "/" and passed through unchanged, so operating
+// system paths work as well as io/fs paths.
+type FS interface {
+	fs.FS
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// Counter measures directory trees on a file system. Its calls may run
+// concurrently and share one bound on the goroutines walking directories.
+type Counter struct {
+	fsys      FS
+	allocated bool
+	sem       chan struct{}
+}
+
+// New creates a counter adding up apparent sizes, or the space allocated
+// on disk when allocated is set. Allocated sizes fall back to apparent ones
+// on file systems without block counts.
+func New(fsys FS, allocated bool) *Counter {
+	return &Counter{fsys: fsys, allocated: allocated, sem: make(chan struct{}, runtime.NumCPU()*2)}
+}
+
+// inode identifies a file across hard links
+type inode struct {
+	dev, ino uint64
+}
+
+// walk holds the state of a single Size or Sizes call
+type walk struct {
+	c     *Counter
+	ctx   context.Context
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	total int64
+	seen  map[inode]bool
+	err   error
+}
+
+// Size returns the combined size of the paths and everything below them.
+// Symlinks are counted themselves and never followed. Unreadable
+// directories are skipped and the first error is returned along with the
+// size of the rest; a cancelled context returns its error.
+func (c *Counter) Size(ctx context.Context, paths ...string) (int64, error) {
+	w := c.newWalk(ctx)
+	for _, path := range paths {
+		w.visit(path, nil)
+	}
+	w.wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.total, w.err
+}
+
+// Sizes returns the size of each path and everything below it, like du
+// with several arguments: the paths are measured in order, and a file
+// hard linked under more than one of them only counts towards the first.
+// Errors are handled as by Size.
+func (c *Counter) Sizes(ctx context.Context, paths ...string) ([]int64, error) {
+	w := c.newWalk(ctx)
+	sizes := make([]int64, len(paths))
+	for i, path := range paths {
+		w.visit(path, nil)
+		w.wg.Wait()
+		sizes[i] = w.total
+		w.total = 0
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return sizes, w.err
+}
+
+// newWalk starts the state of a Size or Sizes call
+func (c *Counter) newWalk(ctx context.Context) *walk {
+	return &walk{c: c, ctx: ctx, seen: map[inode]bool{}}
+}
+
+// visit counts a file, and descends into it when it is a directory
+func (w *walk) visit(path string, info fs.FileInfo) {
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	if info == nil {
+		var err error
+		if info, err = w.c.fsys.Lstat(path); err != nil {
+			w.fail(err)
+			return
+		}
+	}
+	w.add(info)
+
+	if info.IsDir() {
+		w.readDir(path)
+	}
+}
+
+// add counts the size of a file unless another link to it was counted
+func (w *walk) add(info fs.FileInfo) {
+	size := info.Size()
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if ok && w.c.allocated {
+		size = int64(stat.Blocks) * 512
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if ok && !info.IsDir() && stat.Nlink > 1 {
+		id := inode{uint64(stat.Dev), uint64(stat.Ino)}
+		if w.seen[id] {
+			return
+		}
+		w.seen[id] = true
+	}
+	w.total += size
+}
+
+// readDir visits the entries of a directory, handing subdirectories to
+// other goroutines while there are workers to spare
+func (w *walk) readDir(path string) {
+	entries, err := fs.ReadDir(w.c.fsys, path)
+	if err != nil {
+		w.fail(err)
+	}
+
+	for _, entry := range entries {
+		child := join(path, entry.Name())
+		if !entry.IsDir() {
+			info, err := entry.Info()
+			if err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					w.fail(err)
+				}
+				continue
+			}
+			w.visit(child, info)
+			continue
+		}
+
+		select {
+		case w.c.sem <- struct{}{}:
+			w.wg.Add(1)
+			go func() {
+				defer func() { <-w.c.sem; w.wg.Done() }()
+				w.visit(child, nil)
+			}()
+		default:
+			w.visit(child, nil)
+		}
+	}
+}
+
+// fail records the first error met during the walk
+func (w *walk) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// join joins a directory and a name without doubling the separator
+func join(dir, name string) string {
+	if strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+	return dir + "/" + name
+}
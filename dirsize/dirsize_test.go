@@ -0,0 +1,141 @@
+package dirsize
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// osFS gives the counter access to operating system paths
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error)      { return os.Open(name) }
+func (osFS) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+
+// buildTree creates a tree of known sizes, with a file hard linked into
+// two directories and a symlink to a large file outside the tree
+func buildTree(t *testing.T) (string, int64) {
+	t.Helper()
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "big")
+
+	write := func(name string, size int) {
+		if err := os.WriteFile(name, []byte(strings.Repeat("x", size)), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	for _, dir := range []string{"a", "a/deep", "b"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	write(filepath.Join(root, "top"), 100)
+	write(filepath.Join(root, "a", "deep", "file"), 1000)
+	write(filepath.Join(root, "b", "linked"), 500)
+	write(outside, 100000)
+	if err := os.Link(filepath.Join(root, "b", "linked"), filepath.Join(root, "a", "linked")); err != nil {
+		t.Fatalf("Failed to create hard link: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "a", "symlink")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	// Directories count their own size, as with du --apparent-size
+	var want int64 = 100 + 1000 + 500 + int64(len(outside))
+	for _, dir := range []string{".", "a", "a/deep", "b"} {
+		info, err := os.Lstat(filepath.Join(root, dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += info.Size()
+	}
+	return root, want
+}
+
+func TestSize(t *testing.T) {
+	root, want := buildTree(t)
+	counter := New(osFS{}, false)
+
+	got, err := counter.Size(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Size() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("Size() = %d, want %d", got, want)
+	}
+
+	// A hard link is counted once across all the paths of a call, but
+	// again in a separate call
+	a, _ := counter.Size(context.Background(), filepath.Join(root, "a"))
+	b, _ := counter.Size(context.Background(), filepath.Join(root, "b"))
+	both, _ := counter.Size(context.Background(), filepath.Join(root, "a"), filepath.Join(root, "b"))
+	if both != a+b-500 {
+		t.Errorf("Size(a, b) = %d, want %d", both, a+b-500)
+	}
+
+	// A single file is its own size
+	if got, err := counter.Size(context.Background(), filepath.Join(root, "top")); err != nil || got != 100 {
+		t.Errorf("Size(top) = %d, %v, want 100", got, err)
+	}
+}
+
+func TestSizes(t *testing.T) {
+	root, _ := buildTree(t)
+	counter := New(osFS{}, false)
+	a, _ := counter.Size(context.Background(), filepath.Join(root, "a"))
+	b, _ := counter.Size(context.Background(), filepath.Join(root, "b"))
+
+	// The hard link counts towards whichever path comes first
+	sizes, err := counter.Sizes(context.Background(), filepath.Join(root, "a"), filepath.Join(root, "b"))
+	if err != nil || len(sizes) != 2 || sizes[0] != a || sizes[1] != b-500 {
+		t.Errorf("Sizes(a, b) = %v, %v, want [%d %d]", sizes, err, a, b-500)
+	}
+	sizes, err = counter.Sizes(context.Background(), filepath.Join(root, "b"), filepath.Join(root, "a"))
+	if err != nil || len(sizes) != 2 || sizes[0] != b || sizes[1] != a-500 {
+		t.Errorf("Sizes(b, a) = %v, %v, want [%d %d]", sizes, err, b, a-500)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := counter.Sizes(ctx, root); err != context.Canceled {
+		t.Errorf("Sizes() with a cancelled context error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestSizeAllocated(t *testing.T) {
+	root, _ := buildTree(t)
+	var want int64
+	seen := map[uint64]bool{}
+	_ = filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		stat := info.Sys().(*syscall.Stat_t)
+		if !seen[stat.Ino] {
+			seen[stat.Ino] = true
+			want += stat.Blocks * 512
+		}
+		return nil
+	})
+
+	got, err := New(osFS{}, true).Size(context.Background(), root)
+	if err != nil || got != want {
+		t.Errorf("Size() allocated = %d, %v, want %d", got, err, want)
+	}
+}
+
+func TestSizeErrors(t *testing.T) {
+	root, _ := buildTree(t)
+	counter := New(osFS{}, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := counter.Size(ctx, root); err != context.Canceled {
+		t.Errorf("Size() with a cancelled context error = %v, want %v", err, context.Canceled)
+	}
+
+	if _, err := counter.Size(context.Background(), filepath.Join(root, "missing")); err == nil {
+		t.Errorf("Size(missing) expected error")
+	}
+}
@@ -0,0 +1,106 @@
+package filepaths
+
+import (
+	"os"
+	"os/user"
+	"strings"
+)
+
+// HomeDirFunc finds the home directory of the user with a name, or of the
+// current user when the name is empty
+type HomeDirFunc func(username string) (string, error)
+
+// ExpandTilde replaces a leading ~ or ~user in a path with a home directory,
+// taking $HOME for the current user as the shell does
+func ExpandTilde(path string) (string, error) {
+	return ExpandTildeWith(path, userHomeDir)
+}
+
+// ExpandTildeWith replaces a leading ~ or ~user in a path with the home
+// directory found by homeDir. Paths without a leading tilde are returned
+// unchanged.
+func ExpandTildeWith(path string, homeDir HomeDirFunc) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+
+	name, rest, found := strings.Cut(path[1:], "/")
+	home, err := homeDir(name)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return home, nil
+	}
+
+	// A home of / should not give //documents
+	return strings.TrimSuffix(home, "/") + "/" + rest, nil
+}
+
+// userHomeDir looks up home directories through $HOME and os/user
+func userHomeDir(username string) (string, error) {
+	if username == "" {
+		if home := os.Getenv("HOME"); home != "" {
+			return home, nil
+		}
+		u, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+		return u.HomeDir, nil
+	}
+
+	u, err := user.Lookup(username)
+	if err != nil {
+		return "", err
+	}
+	return u.HomeDir, nil
+}
+
+// ExpandEnv replaces $VAR and ${VAR} in a path with the values of
+// environment variables
+func ExpandEnv(path string) string {
+	return ExpandEnvWith(path, os.Getenv)
+}
+
+// ExpandEnvWith replaces $VAR and ${VAR} in a path with the values given by
+// getenv. Names are made of letters, digits and underscores and do not
+// start with a digit. A $ that does not start a name, and a ${ without a
+// valid name and closing brace, are kept as they are.
+func ExpandEnvWith(path string, getenv func(string) string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] != '$' {
+			b.WriteByte(path[i])
+			continue
+		}
+
+		rest := path[i+1:]
+		if strings.HasPrefix(rest, "{") {
+			name, _, found := strings.Cut(rest[1:], "}")
+			if found && nameLength(name) == len(name) && name != "" {
+				b.WriteString(getenv(name))
+				i += len(name) + 2
+				continue
+			}
+		} else if n := nameLength(rest); n > 0 {
+			b.WriteString(getenv(rest[:n]))
+			i += n
+			continue
+		}
+		b.WriteByte('$')
+	}
+	return b.String()
+}
+
+// nameLength returns the length of the variable name at the start of s
+func nameLength(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return i
+		}
+	}
+	return len(s)
+}
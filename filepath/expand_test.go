@@ -0,0 +1,101 @@
+package filepaths
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// homes stands in for the user database
+func homes(username string) (string, error) {
+	switch username {
+	case "":
+		return "/home/me", nil
+	case "root":
+		return "/", nil
+	case "bob":
+		return "/home/bob/", nil
+	}
+	return "", fmt.Errorf("unknown user %s", username)
+}
+
+func TestExpandTildeWith(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"~", "/home/me", false},
+		{"~/docs", "/home/me/docs", false},
+		{"~/", "/home/me/", false},
+		{"~bob", "/home/bob/", false},
+		{"~bob/docs/", "/home/bob/docs/", false},
+		{"~root/etc", "/etc", false},
+		{"~nobody/x", "", true},
+		{"a/~/b", "a/~/b", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := ExpandTildeWith(tt.path, homes)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ExpandTildeWith(%q) = %q, %v, want %q, error %v", tt.path, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestExpandTilde(t *testing.T) {
+	t.Setenv("HOME", "/tmp/home")
+	if got, err := ExpandTilde("~/x"); err != nil || got != "/tmp/home/x" {
+		t.Errorf("ExpandTilde(%q) = %q, %v, want %q", "~/x", got, err, "/tmp/home/x")
+	}
+}
+
+func TestExpandEnvWith(t *testing.T) {
+	env := map[string]string{"HOME": "/home/me", "A_1": "one", "EMPTY": ""}
+	getenv := func(name string) string { return env[name] }
+
+	tests := []struct {
+		path, want string
+	}{
+		{"$HOME/docs", "/home/me/docs"},
+		{"${HOME}docs", "/home/medocs"},
+		{"$A_1.$A_1", "one.one"},
+		{"x$EMPTY/$UNSET/y", "x//y"},
+		{"$", "$"},
+		{"a$/b$1c", "a$/b$1c"},
+		{"${", "${"},
+		{"${HOME", "${HOME"},
+		{"${}x", "${}x"},
+		{"${A-1}", "${A-1}"},
+		{"$$HOME", "$/home/me"},
+		{"price: 5$", "price: 5$"},
+	}
+
+	for _, tt := range tests {
+		if got := ExpandEnvWith(tt.path, getenv); got != tt.want {
+			t.Errorf("ExpandEnvWith(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func FuzzExpandEnv(f *testing.F) {
+	for _, seed := range []string{"$HOME/x", "${HOME}", "a$b_c$", "$1", "${x", "$$"} {
+		f.Add(seed)
+	}
+	brackets := func(name string) string { return "<" + name + ">" }
+	f.Fuzz(func(t *testing.T, path string) {
+		got := ExpandEnvWith(path, brackets)
+
+		// os.Expand also takes $1, $$, ${} and the like as variables, so
+		// the two only agree when every $ starts a name
+		for i := 0; i < len(path); i++ {
+			if path[i] == '$' && nameLength(path[i+1:]) == 0 {
+				return
+			}
+		}
+		if want := os.Expand(path, brackets); got != want {
+			t.Errorf("ExpandEnvWith(%q) = %q, os.Expand gives %q", path, got, want)
+		}
+	})
+}
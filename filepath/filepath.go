@@ -1,86 +1,126 @@
 package filepaths
 
 import (
-	"os/user"
+	"fmt"
 	"strings"
 )
 
-func GetParentDir(path string) string {
-	// Remove trailing slashes from the path
-	if path != "/" {
-		path = strings.TrimRight(path, "/")
-	}
-	if path == "." {
-		return ".."
+// Clean returns the shortest path naming the same file as path by lexical
+// processing alone: repeated slashes and . elements are dropped, and each
+// .. removes the element before it. A .. at the start of a rooted path is
+// dropped too, since the parent of / is /. The result only ends in a slash
+// for the root, and is . for an empty path.
+func Clean(path string) string {
+	if path == "" {
+		return "."
 	}
+	rooted := path[0] == '/'
 
-	// Find the last index of the separator.
-	lastSlashIndex := strings.LastIndex(path, "/")
+	// Leading .. elements of a relative path cannot be removed
+	var elems []string
+	fixed := 0
+	for _, elem := range strings.Split(path, "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			if len(elems) > fixed {
+				elems = elems[:len(elems)-1]
+			} else if !rooted {
+				elems = append(elems, "..")
+				fixed++
+			}
+		default:
+			elems = append(elems, elem)
+		}
+	}
 
-	// If there is no Slash in the path return "." as it the current directory
-	if lastSlashIndex == -1 {
+	cleaned := strings.Join(elems, "/")
+	if rooted {
+		return "/" + cleaned
+	}
+	if cleaned == "" {
 		return "."
 	}
+	return cleaned
+}
 
-	// Return the substring before the last slash, which is the parent directory
-	parentDir := path[:lastSlashIndex]
-	if parentDir == "" {
-		return "/"
+// Join joins the non-empty elements with slashes and cleans the result. It
+// returns an empty string when every element is empty.
+func Join(elems ...string) string {
+	var parts []string
+	for _, elem := range elems {
+		if elem != "" {
+			parts = append(parts, elem)
+		}
 	}
-	return parentDir
+	if len(parts) == 0 {
+		return ""
+	}
+	return Clean(strings.Join(parts, "/"))
 }
 
-func JoinPaths(basePath string, additionalPaths ...string) string {
-	// Ensurnig the base ends with a separator.
-	if !strings.HasSuffix(basePath, "/") {
-		basePath += "/"
+// Rel returns a path to targpath relative to basepath, lexically, so that
+// Join(basepath, Rel(basepath, targpath)) is Clean(targpath). It fails when
+// one path is rooted and the other is not, or when basepath climbs out
+// through .. elements the result cannot retrace.
+func Rel(basepath, targpath string) (string, error) {
+	base, targ := Clean(basepath), Clean(targpath)
+	if base == targ {
+		return ".", nil
 	}
-
-	// Join the additional paths
-	for _, path := range additionalPaths {
-		// Removind additional slashes from the paths
-		path = strings.TrimLeft(path, "/")
-		basePath += path + "/"
+	if (base[0] == '/') != (targ[0] == '/') {
+		return "", fmt.Errorf("Rel: can't make %s relative to %s", targpath, basepath)
 	}
-	return strings.TrimRight(basePath, "/")
-}
 
-// ExpandTilde replaces the tilde (~) in a path with the current user's home directory
-func ExpandTilde(path string) (string, error) {
-	// If the path doesn't start with a tilde, return it unchanged
-	if !strings.HasPrefix(path, "~") {
-		return path, nil
+	baseElems, targElems := elements(base), elements(targ)
+	common := 0
+	for common < len(baseElems) && common < len(targElems) && baseElems[common] == targElems[common] {
+		common++
 	}
 
-	// Get the current user
-	currentUser, err := user.Current()
-	if err != nil {
-		return "", err
+	var rel []string
+	for _, elem := range baseElems[common:] {
+		if elem == ".." {
+			return "", fmt.Errorf("Rel: can't make %s relative to %s", targpath, basepath)
+		}
+		rel = append(rel, "..")
 	}
+	rel = append(rel, targElems[common:]...)
+	return strings.Join(rel, "/"), nil
+}
 
-	// Replace the tilde with the home directory
-	if path == "~" {
-		return currentUser.HomeDir, nil
+// elements splits a clean path into its elements, of which / and . have none
+func elements(path string) []string {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" || path == "." {
+		return nil
 	}
+	return strings.Split(path, "/")
+}
+
+// GetParentDir returns the lexical parent of path: the parent of . is ..,
+// the parent of a/.. is .. and the parent of / is /. Symlinks are not
+// followed, so use Realpath first where they matter.
+func GetParentDir(path string) string {
+	return Join(path, "..")
+}
+
+// JoinPaths joins a base path with additional elements like Join, but keeps
+// a trailing slash on the last element, which restricts a name to
+// directories and symlinks to them.
+func JoinPaths(basePath string, additionalPaths ...string) string {
+	elems := append([]string{basePath}, additionalPaths...)
+	joined := Join(elems...)
 
-	// Handle paths like "~/documents" or "~username/documents"
-	if path[1] == '/' {
-		// Path like "~/documents"
-		return strings.Replace(path, "~", currentUser.HomeDir, 1), nil
-	} else {
-		// Path like "~username/documents"
-		parts := strings.SplitN(path[1:], "/", 2)
-		username := parts[0]
-		
-		// Get the specified user
-		u, err := user.Lookup(username)
-		if err != nil {
-			return "", err
+	// Find the last element that counted towards the result
+	for i := len(elems) - 1; i >= 0; i-- {
+		if elems[i] == "" {
+			continue
 		}
-		
-		if len(parts) == 1 {
-			return u.HomeDir, nil
+		if strings.HasSuffix(elems[i], "/") && !strings.HasSuffix(joined, "/") {
+			joined += "/"
 		}
-		return u.HomeDir + "/" + parts[1], nil
+		break
 	}
+	return joined
 }
@@ -0,0 +1,134 @@
+package filepaths
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"", "."},
+		{"/", "/"},
+		{"a//b///c/", "a/b/c"},
+		{"./a/./b/.", "a/b"},
+		{"a/b/../c", "a/c"},
+		{"a/../..", ".."},
+		{"../../a/..", "../.."},
+		{"/../a/../../b", "/b"},
+		{"//", "/"},
+	}
+
+	for _, tt := range tests {
+		if got := Clean(tt.path); got != tt.want {
+			t.Errorf("Clean(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRel(t *testing.T) {
+	tests := []struct {
+		base, targ, want string
+		wantErr          bool
+	}{
+		{"a/b", "a/b", ".", false},
+		{"a/b", "a/c/d", "../c/d", false},
+		{"/", "/usr/bin", "usr/bin", false},
+		{".", "a", "a", false},
+		{"a", ".", "..", false},
+		{"a", "../b", "../../b", false},
+		{"..", "../b", "b", false},
+		{"../a", "b", "", true},
+		{"/a", "a", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Rel(tt.base, tt.targ)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Rel(%q, %q) = %q, %v, want %q, error %v", tt.base, tt.targ, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestGetParentDir(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{".", ".."},
+		{"..", "../.."},
+		{"a", "."},
+		{"a/b/", "a"},
+		{"a//b", "a"},
+		{"a/..", ".."},
+		{"/a", "/"},
+		{"/", "/"},
+	}
+
+	for _, tt := range tests {
+		if got := GetParentDir(tt.path); got != tt.want {
+			t.Errorf("GetParentDir(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestJoinPaths(t *testing.T) {
+	tests := []struct {
+		base  string
+		elems []string
+		want  string
+	}{
+		{"a", []string{"b", "c"}, "a/b/c"},
+		{"a/", []string{"/b"}, "a/b"},
+		{"a", []string{"b/"}, "a/b/"},
+		{"a", []string{"b/", ""}, "a/b/"},
+		{"a", []string{"../b/./c/"}, "b/c/"},
+		{"", []string{"a"}, "a"},
+		{"/", nil, "/"},
+		{"a", []string{".."}, "."},
+	}
+
+	for _, tt := range tests {
+		if got := JoinPaths(tt.base, tt.elems...); got != tt.want {
+			t.Errorf("JoinPaths(%q, %q) = %q, want %q", tt.base, tt.elems, got, tt.want)
+		}
+	}
+}
+
+func FuzzClean(f *testing.F) {
+	for _, seed := range []string{"", "/", "a//b/./../c/", "../../x", "/..//.", "..a/.b/..."} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, path string) {
+		if got, want := Clean(path), filepath.Clean(path); got != want {
+			t.Errorf("Clean(%q) = %q, filepath.Clean gives %q", path, got, want)
+		}
+	})
+}
+
+func FuzzJoin(f *testing.F) {
+	f.Add("a", "b")
+	f.Add("", "")
+	f.Add("/a/", "../../b/")
+	f.Add("..", "")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		if got, want := Join(a, b), filepath.Join(a, b); got != want {
+			t.Errorf("Join(%q, %q) = %q, filepath.Join gives %q", a, b, got, want)
+		}
+	})
+}
+
+func FuzzRel(f *testing.F) {
+	f.Add("a/b", "a/c")
+	f.Add("/", "/usr")
+	f.Add("../a", "b")
+	f.Add("..", "../..")
+	f.Add("a", "/a")
+	f.Fuzz(func(t *testing.T, base, targ string) {
+		got, err := Rel(base, targ)
+		want, wantErr := filepath.Rel(base, targ)
+		if got != want || (err != nil) != (wantErr != nil) {
+			t.Errorf("Rel(%q, %q) = %q, %v, filepath.Rel gives %q, %v", base, targ, got, err, want, wantErr)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package filepaths
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+// maxSymlinks bounds the symlinks Realpath follows, as the kernel does
+const maxSymlinks = 40
+
+// Realpath returns the absolute path of a file with every symlink along
+// the way resolved and no . or .. elements, like realpath(3). Relative
+// paths start from the working directory. Every element must exist, and
+// all but the last must be directories.
+func Realpath(path string) (string, error) {
+	if path == "" {
+		return "", &os.PathError{Op: "realpath", Path: path, Err: syscall.ENOENT}
+	}
+	if !strings.HasPrefix(path, "/") {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		path = wd + "/" + path
+	}
+
+	// The resolved prefix never holds a symlink, so .. can be lexical
+	resolved := "/"
+	rest := path
+	links := 0
+	for rest != "" {
+		elem, remaining, more := strings.Cut(strings.TrimLeft(rest, "/"), "/")
+		rest = remaining
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			resolved = GetParentDir(resolved)
+			continue
+		}
+
+		next := Join(resolved, elem)
+		info, err := os.Lstat(next)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			// A trailing slash or more elements need a directory
+			if more && !info.IsDir() {
+				return "", &os.PathError{Op: "realpath", Path: path, Err: syscall.ENOTDIR}
+			}
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", &os.PathError{Op: "realpath", Path: path, Err: syscall.ELOOP}
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(target, "/") {
+			resolved = "/"
+		}
+		if more {
+			target += "/" + rest
+		}
+		rest = target
+	}
+	return resolved, nil
+}
@@ -0,0 +1,130 @@
+package filepaths
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// symlinkTree creates directories, a file and symlinks of every kind in a
+// temporary directory and returns its resolved path
+func symlinkTree(t testing.TB) string {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"a/b", "c"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "a/b/file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"c/up":       "../a/b",
+		"c/abs":      root + "/a",
+		"c/chain":    "up/..",
+		"c/tofile":   "../a/b/file",
+		"c/dangling": "missing",
+		"c/loop":     "loop",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestRealpath(t *testing.T) {
+	root := symlinkTree(t)
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr error
+	}{
+		{"a/b/file", "a/b/file", nil},
+		{"c/up", "a/b", nil},
+		{"c/up/..", "a", nil},
+		{"c/up/../../c", "c", nil},
+		{"c//abs/./b/", "a/b", nil},
+		{"c/chain", "a", nil},
+		{"c/tofile", "a/b/file", nil},
+		{"c/tofile/", "", syscall.ENOTDIR},
+		{"a/b/file/..", "", syscall.ENOTDIR},
+		{"c/dangling", "", os.ErrNotExist},
+		{"c/loop", "", syscall.ELOOP},
+	}
+
+	for _, tt := range tests {
+		got, err := Realpath(root + "/" + tt.path)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Realpath(%q) = %q, %v, want error %v", tt.path, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if want := root + "/" + tt.want; err != nil || got != want {
+			t.Errorf("Realpath(%q) = %q, %v, want %q", tt.path, got, err, want)
+		}
+	}
+
+	// Relative paths start at the working directory
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root + "/c"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	if got, err := Realpath("up/file"); err != nil || got != root+"/a/b/file" {
+		t.Errorf("Realpath(%q) = %q, %v, want %q", "up/file", got, err, root+"/a/b/file")
+	}
+	if got, err := Realpath("."); err != nil || got != root+"/c" {
+		t.Errorf("Realpath(%q) = %q, %v, want %q", ".", got, err, root+"/c")
+	}
+}
+
+func FuzzRealpath(f *testing.F) {
+	root := symlinkTree(f)
+	for _, seed := range []string{"a/b/file", "c/up/..", "c/chain/b", "c/tofile/", "c/loop", "c//abs/../c/dangling", "../.."} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, path string) {
+		if path == "" || strings.ContainsRune(path, 0) {
+			return
+		}
+		path = root + "/" + path
+		got, err := Realpath(path)
+		want, wantErr := filepath.EvalSymlinks(path)
+		if (err != nil) != (wantErr != nil) {
+			t.Fatalf("Realpath(%q) = %q, %v, filepath.EvalSymlinks gives %q, %v", path, got, err, want, wantErr)
+		}
+		if err != nil {
+			return
+		}
+		if got != want {
+			t.Errorf("Realpath(%q) = %q, filepath.EvalSymlinks gives %q", path, got, want)
+		}
+
+		// The result names the same file
+		gotInfo, err := os.Stat(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantInfo, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !os.SameFile(gotInfo, wantInfo) {
+			t.Errorf("Realpath(%q) = %q, which is a different file", path, got)
+		}
+	})
+}
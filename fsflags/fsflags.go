@@ -0,0 +1,90 @@
+// Package fsflags reads the inode flags of files, such as immutable and
+// append-only, which Linux file systems keep apart from the mode bits and
+// lsattr shows.
+package fsflags
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// getFlags is the FS_IOC_GETFLAGS ioctl request
+const getFlags = 0x80086601
+
+// Flags of an inode, as returned by FS_IOC_GETFLAGS
+const (
+	SecureDelete   = 0x00000001
+	Undelete       = 0x00000002
+	Compress       = 0x00000004
+	Sync           = 0x00000008
+	Immutable      = 0x00000010
+	AppendOnly     = 0x00000020
+	NoDump         = 0x00000040
+	NoAtime        = 0x00000080
+	NoCompress     = 0x00000400
+	Encrypted      = 0x00000800
+	Indexed        = 0x00001000
+	JournalData    = 0x00004000
+	NoTail         = 0x00008000
+	DirSync        = 0x00010000
+	TopDir         = 0x00020000
+	Extents        = 0x00080000
+	Verity         = 0x00100000
+	NoCOW          = 0x00800000
+	DAX            = 0x02000000
+	InlineData     = 0x10000000
+	ProjectInherit = 0x20000000
+	Casefold       = 0x40000000
+)
+
+// letters holds each flag with its letter, in the order lsattr prints them
+var letters = []struct {
+	flag   uint32
+	letter byte
+}{
+	{SecureDelete, 's'}, {Undelete, 'u'}, {Sync, 'S'}, {DirSync, 'D'},
+	{Immutable, 'i'}, {AppendOnly, 'a'}, {NoDump, 'd'}, {NoAtime, 'A'},
+	{Compress, 'c'}, {Encrypted, 'E'}, {JournalData, 'j'}, {Indexed, 'I'},
+	{NoTail, 't'}, {TopDir, 'T'}, {Extents, 'e'}, {NoCOW, 'C'}, {DAX, 'x'},
+	{Casefold, 'F'}, {InlineData, 'N'}, {ProjectInherit, 'P'}, {Verity, 'V'},
+	{NoCompress, 'm'},
+}
+
+// Format shows flags as lsattr does, with a letter for each flag set and a
+// dash for each one clear, such as "----i---------e-------"
+func Format(flags uint32) string {
+	b := make([]byte, len(letters))
+	for i, l := range letters {
+		b[i] = '-'
+		if flags&l.flag != 0 {
+			b[i] = l.letter
+		}
+	}
+	return string(b)
+}
+
+// Get returns the flags of a file. Like lsattr it only reads regular files
+// and directories, and fails with ENOTTY on file systems without flags.
+func Get(path string) (uint32, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() && !info.IsDir() {
+		return 0, syscall.ENOTTY
+	}
+
+	// Opening without blocking keeps fifos and locked files from hanging
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.Close(fd)
+
+	var flags uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), getFlags, uintptr(unsafe.Pointer(&flags))); errno != 0 {
+		return 0, errno
+	}
+	return flags, nil
+}
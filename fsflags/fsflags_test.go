@@ -0,0 +1,50 @@
+package fsflags
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := map[uint32]string{
+		0:                     "----------------------",
+		Extents:               "--------------e-------",
+		Immutable | Extents:   "----i---------e-------",
+		AppendOnly | NoDump:   "-----ad---------------",
+		SecureDelete | Verity: "s-------------------V-",
+		NoCompress | Casefold: "-----------------F---m",
+		0x00000100:            "----------------------",
+	}
+	for flags, want := range tests {
+		if got := Format(flags); got != want {
+			t.Errorf("Format(%#x) = %s, want %s", flags, got, want)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Get(path); err != nil {
+		t.Skipf("File system has no inode flags: %v", err)
+	}
+	if _, err := Get(dir); err != nil {
+		t.Errorf("Get() of a directory error = %v", err)
+	}
+
+	// Symlinks have no flags of their own
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("file", link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Get(link); !errors.Is(err, syscall.ENOTTY) {
+		t.Errorf("Get() of a symlink error = %v, want ENOTTY", err)
+	}
+}
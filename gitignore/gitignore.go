@@ -0,0 +1,257 @@
+package gitignore
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Pattern is a single parsed line of a gitignore file
+type Pattern struct {
+	segments []string
+	base     string
+	negate   bool
+	dirOnly  bool
+}
+
+// ParseLine parses one line of a gitignore file found in the directory base,
+// given relative to the repository root. It returns false for blank lines
+// and comments.
+func ParseLine(line, base string) (Pattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+
+	// Trailing spaces are ignored unless they are escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Pattern{}, false
+	}
+
+	p := Pattern{base: base}
+
+	// A leading ! negates the pattern, \! and \# escape a literal character
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+
+	// A trailing slash only matches directories
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return Pattern{}, false
+	}
+
+	// A pattern with a slash at the start or in the middle is anchored to
+	// its directory, anything else matches a name at any depth
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+
+	for _, segment := range strings.Split(line, "/") {
+		// path.Match spells negated character classes [^...]
+		p.segments = append(p.segments, strings.ReplaceAll(segment, "[!", "[^"))
+	}
+
+	return p, true
+}
+
+// Parse reads every pattern from a gitignore file found in the directory base
+func Parse(r io.Reader, base string) []Pattern {
+	var patterns []Pattern
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if p, ok := ParseLine(scanner.Text(), base); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// ReadFile reads the patterns of a gitignore file, returning none if it
+// cannot be read
+func ReadFile(filename, base string) []Pattern {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	return Parse(f, base)
+}
+
+// match reports whether the pattern matches a slash separated path relative
+// to the repository root
+func (p Pattern) match(name string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	// Patterns only apply below the directory of their gitignore file
+	if p.base != "" {
+		if !strings.HasPrefix(name, p.base+"/") {
+			return false
+		}
+		name = name[len(p.base)+1:]
+	}
+
+	return matchSegments(p.segments, strings.Split(name, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// segment of ** matches any number of path segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// A trailing /** matches everything inside
+			if len(pattern) == 1 {
+				return len(segments) > 0
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+
+	return len(segments) == 0
+}
+
+// Matcher decides whether paths are ignored by an ordered set of patterns,
+// where later patterns take precedence over earlier ones
+type Matcher struct {
+	patterns []Pattern
+}
+
+// NewMatcher creates a matcher from patterns in increasing precedence
+func NewMatcher(patterns []Pattern) *Matcher {
+	return &Matcher{patterns: patterns}
+}
+
+// With returns a new matcher with extra patterns that take precedence over
+// the existing ones, as a deeper gitignore file does
+func (m *Matcher) With(patterns []Pattern) *Matcher {
+	if len(patterns) == 0 {
+		return m
+	}
+
+	combined := make([]Pattern, 0, len(m.patterns)+len(patterns))
+	combined = append(combined, m.patterns...)
+	combined = append(combined, patterns...)
+	return &Matcher{patterns: combined}
+}
+
+// Match reports whether a slash separated path relative to the repository
+// root is ignored. A path inside an ignored directory is always ignored,
+// since git cannot re-include a file whose parent is excluded.
+func (m *Matcher) Match(name string, isDir bool) bool {
+	segments := strings.Split(name, "/")
+	for i := 1; i < len(segments); i++ {
+		if m.matchPath(strings.Join(segments[:i], "/"), true) {
+			return true
+		}
+	}
+
+	return m.matchPath(name, isDir)
+}
+
+// matchPath applies the last pattern matching the path
+func (m *Matcher) matchPath(name string, isDir bool) bool {
+	for i := len(m.patterns) - 1; i >= 0; i-- {
+		if m.patterns[i].match(name, isDir) {
+			return !m.patterns[i].negate
+		}
+	}
+	return false
+}
+
+// FindRepoRoot walks up from dir looking for the top of a git working tree
+func FindRepoRoot(dir string) (string, bool) {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// NewRepoMatcher creates a matcher for the repository rooted at root from
+// the global excludes file and .git/info/exclude. The gitignore files of
+// each directory are added on top of it with With during traversal.
+func NewRepoMatcher(root string) *Matcher {
+	home, _ := os.UserHomeDir()
+	patterns := ReadFile(GlobalExcludesFile(home, os.Getenv("XDG_CONFIG_HOME")), "")
+	patterns = append(patterns, ReadFile(filepath.Join(root, ".git", "info", "exclude"), "")...)
+	return NewMatcher(patterns)
+}
+
+// GlobalExcludesFile finds the global excludes file, preferring
+// core.excludesFile from ~/.gitconfig over the XDG default location
+func GlobalExcludesFile(home, xdgConfigHome string) string {
+	if file := excludesFileFromConfig(filepath.Join(home, ".gitconfig")); file != "" {
+		if strings.HasPrefix(file, "~/") {
+			file = filepath.Join(home, file[2:])
+		}
+		return file
+	}
+
+	if xdgConfigHome == "" {
+		xdgConfigHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(xdgConfigHome, "git", "ignore")
+}
+
+// excludesFileFromConfig reads core.excludesFile from a git config file
+func excludesFileFromConfig(configFile string) string {
+	f, err := os.Open(configFile)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if ok && section == "core" && strings.EqualFold(strings.TrimSpace(key), "excludesfile") {
+			return strings.Trim(strings.TrimSpace(value), "\"")
+		}
+	}
+
+	return ""
+}
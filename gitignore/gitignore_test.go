@@ -0,0 +1,180 @@
+package gitignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		ok       bool
+		expected Pattern
+	}{
+		{"Blank line", "", false, Pattern{}},
+		{"Comment", "# build output", false, Pattern{}},
+		{"Only spaces", "   ", false, Pattern{}},
+		{"Simple name", "*.o", true, Pattern{segments: []string{"**", "*.o"}}},
+		{"Trailing spaces", "*.o  ", true, Pattern{segments: []string{"**", "*.o"}}},
+		{"Escaped trailing space", "foo\\ ", true, Pattern{segments: []string{"**", "foo\\ "}}},
+		{"Escaped hash", "\\#file", true, Pattern{segments: []string{"**", "#file"}}},
+		{"Escaped bang", "\\!file", true, Pattern{segments: []string{"**", "!file"}}},
+		{"Negation", "!keep.o", true, Pattern{segments: []string{"**", "keep.o"}, negate: true}},
+		{"Directory only", "build/", true, Pattern{segments: []string{"**", "build"}, dirOnly: true}},
+		{"Leading slash", "/build", true, Pattern{segments: []string{"build"}}},
+		{"Middle slash", "doc/*.txt", true, Pattern{segments: []string{"doc", "*.txt"}}},
+		{"Double star", "**/logs", true, Pattern{segments: []string{"**", "logs"}}},
+		{"Negated class", "file[!0-9]", true, Pattern{segments: []string{"**", "file[^0-9]"}}},
+		{"Carriage return", "*.o\r", true, Pattern{segments: []string{"**", "*.o"}}},
+		{"Lone slash", "/", false, Pattern{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseLine(tt.line, "")
+			if ok != tt.ok {
+				t.Fatalf("ParseLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			}
+			if strings.Join(got.segments, "/") != strings.Join(tt.expected.segments, "/") ||
+				got.negate != tt.expected.negate || got.dirOnly != tt.expected.dirOnly {
+				t.Errorf("ParseLine(%q) = %+v, want %+v", tt.line, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		// Plain names match at any depth
+		{"Name at root", "*.o", "main.o", false, true},
+		{"Name in subdirectory", "*.o", "a/b/main.o", false, true},
+		{"Name no match", "*.o", "main.c", false, false},
+		{"Star does not cross slash", "a*b", "a/b", false, false},
+		{"Question mark", "file?.txt", "file1.txt", false, true},
+		{"Character class", "file[0-9].txt", "file7.txt", false, true},
+		{"Negated character class", "file[!0-9].txt", "file7.txt", false, false},
+		{"Range class", "[a-c].go", "b.go", false, true},
+
+		// Directory only patterns
+		{"Dir pattern matches dir", "build/", "build", true, true},
+		{"Dir pattern skips file", "build/", "build", false, false},
+		{"Dir pattern nested", "build/", "src/build", true, true},
+		{"Contents of ignored dir", "build/", "build/out.bin", false, true},
+		{"Deep contents of ignored dir", "build/", "build/a/b/c", false, true},
+
+		// Anchored patterns
+		{"Leading slash at root", "/build", "build", true, true},
+		{"Leading slash not nested", "/build", "src/build", true, false},
+		{"Middle slash anchored", "doc/*.txt", "doc/a.txt", false, true},
+		{"Middle slash not nested", "doc/*.txt", "x/doc/a.txt", false, false},
+		{"Middle slash no deeper", "doc/*.txt", "doc/sub/a.txt", false, false},
+
+		// Double star
+		{"Leading double star root", "**/logs", "logs", true, true},
+		{"Leading double star nested", "**/logs", "a/b/logs", true, true},
+		{"Leading double star with file", "**/logs/debug.log", "x/logs/debug.log", false, true},
+		{"Trailing double star", "abc/**", "abc/x/y", false, true},
+		{"Trailing double star not dir itself", "abc/**", "abc", true, false},
+		{"Middle double star zero dirs", "a/**/b", "a/b", false, true},
+		{"Middle double star one dir", "a/**/b", "a/x/b", false, true},
+		{"Middle double star many dirs", "a/**/b", "a/x/y/z/b", false, true},
+		{"Middle double star wrong root", "a/**/b", "c/x/b", false, false},
+		{"Double star inside segment", "a**b", "axyb", false, true},
+
+		// Negation and precedence
+		{"Negation re-includes", "*.log\n!keep.log", "keep.log", false, false},
+		{"Negation others ignored", "*.log\n!keep.log", "drop.log", false, true},
+		{"Last match wins", "!keep.log\n*.log", "keep.log", false, true},
+		{"Cannot re-include inside ignored dir", "build/\n!build/keep", "build/keep", false, true},
+		{"Re-include with dir star", "build/*\n!build/keep", "build/keep", false, false},
+		{"Re-include with dir star others", "build/*\n!build/keep", "build/drop", false, true},
+
+		// Escapes and comments
+		{"Escaped hash", "\\#notes", "#notes", false, true},
+		{"Comment ignored", "#notes", "#notes", false, false},
+		{"Escaped bang", "\\!important", "!important", false, true},
+		{"Escaped star", "\\*.txt", "*.txt", false, true},
+		{"Escaped star literal only", "\\*.txt", "a.txt", false, false},
+		{"Escaped trailing space", "name\\ ", "name ", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMatcher(Parse(strings.NewReader(tt.patterns), ""))
+			if got := m.Match(tt.path, tt.isDir); got != tt.expected {
+				t.Errorf("Match(%q, %v) with %q = %v, want %v", tt.path, tt.isDir, tt.patterns, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMatcherWithNestedFiles(t *testing.T) {
+	root := NewMatcher(Parse(strings.NewReader("*.tmp\n/out"), ""))
+	sub := root.With(Parse(strings.NewReader("!keep.tmp\n/gen\n*.log"), "src"))
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"a.tmp", false, true},
+		{"src/a.tmp", false, true},
+		{"src/keep.tmp", false, false},
+		{"keep.tmp", false, true},
+		{"out", true, true},
+		{"src/out", true, false},
+		{"src/gen", true, true},
+		{"gen", true, false},
+		{"src/x/gen", true, false},
+		{"src/x/debug.log", false, true},
+		{"debug.log", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := sub.Match(tt.path, tt.isDir); got != tt.expected {
+			t.Errorf("Match(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.expected)
+		}
+	}
+
+	// The parent matcher is left untouched
+	if root.Match("src/keep.tmp", false) != true {
+		t.Errorf("With() modified the parent matcher")
+	}
+}
+
+func TestFindRepoRoot(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(tmpDir, ".git"), 0755)
+	_ = os.MkdirAll(filepath.Join(tmpDir, "a", "b"), 0755)
+
+	root, ok := FindRepoRoot(filepath.Join(tmpDir, "a", "b"))
+	if !ok || root != tmpDir {
+		t.Errorf("FindRepoRoot() = %q, %v, want %q, true", root, ok, tmpDir)
+	}
+}
+
+func TestGlobalExcludesFile(t *testing.T) {
+	home := t.TempDir()
+
+	if got := GlobalExcludesFile(home, ""); got != filepath.Join(home, ".config", "git", "ignore") {
+		t.Errorf("GlobalExcludesFile() default = %q", got)
+	}
+	if got := GlobalExcludesFile(home, "/xdg"); got != "/xdg/git/ignore" {
+		t.Errorf("GlobalExcludesFile() with XDG_CONFIG_HOME = %q", got)
+	}
+
+	config := "[user]\n\tname = someone\n[core]\n\texcludesFile = ~/.gitignore_global\n"
+	_ = os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(config), 0644)
+	if got := GlobalExcludesFile(home, "/xdg"); got != filepath.Join(home, ".gitignore_global") {
+		t.Errorf("GlobalExcludesFile() with core.excludesFile = %q", got)
+	}
+}
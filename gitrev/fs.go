@@ -0,0 +1,350 @@
+package gitrev
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLinkHops bounds how many symlinks Stat follows inside a tree
+const maxLinkHops = 40
+
+// Git tree entry modes
+const (
+	modeTree    = 0o040000
+	modeBlob    = 0o100644
+	modeExec    = 0o100755
+	modeSymlink = 0o120000
+	modeGitlink = 0o160000
+)
+
+// treeEntry is a single entry of a tree object
+type treeEntry struct {
+	name string
+	mode uint32
+	hash Hash
+}
+
+// FS is a read-only file system holding the tree of a commit. Every file
+// carries the commit time, and submodules appear as empty directories. It
+// is safe for concurrent use.
+type FS struct {
+	repo    *Repo
+	root    Hash
+	modTime time.Time
+
+	// trees caches the trees read so far
+	mu    sync.Mutex
+	trees map[Hash][]treeEntry
+}
+
+// TreeFS opens the tree of the commit a revision names
+func (r *Repo) TreeFS(rev string) (*FS, error) {
+	h, err := r.Resolve(rev)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.readCommit(h)
+	if err != nil {
+		return nil, err
+	}
+	return &FS{repo: r, root: c.tree, modTime: c.time, trees: map[Hash][]treeEntry{}}, nil
+}
+
+// readTree parses a tree object, made of "mode name\0" followed by the
+// 20 byte object name for each entry
+func (f *FS) readTree(h Hash) ([]treeEntry, error) {
+	f.mu.Lock()
+	entries, ok := f.trees[h]
+	f.mu.Unlock()
+	if ok {
+		return entries, nil
+	}
+
+	typ, data, err := f.repo.readObject(h)
+	if err != nil {
+		return nil, err
+	}
+	if typ != typeTree {
+		return nil, fmt.Errorf("object %s is not a tree", h)
+	}
+
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if space < 0 || nul < space || len(data) < nul+21 {
+			return nil, fmt.Errorf("malformed tree %s", h)
+		}
+		mode, err := strconv.ParseUint(string(data[:space]), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("malformed tree %s", h)
+		}
+
+		entry := treeEntry{name: string(data[space+1 : nul]), mode: uint32(mode)}
+		copy(entry.hash[:], data[nul+1:nul+21])
+		entries = append(entries, entry)
+		data = data[nul+21:]
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	f.mu.Lock()
+	f.trees[h] = entries
+	f.mu.Unlock()
+	return entries, nil
+}
+
+// lookup finds the tree entry for a valid io/fs path without following
+// symlinks. The root is returned as a tree entry named ".".
+func (f *FS) lookup(op, name string) (treeEntry, error) {
+	if !fs.ValidPath(name) {
+		return treeEntry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	entry := treeEntry{name: ".", mode: modeTree, hash: f.root}
+	if name == "." {
+		return entry, nil
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if entry.mode != modeTree {
+			return treeEntry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		entries, err := f.readTree(entry.hash)
+		if err != nil {
+			return treeEntry{}, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+
+		i := sort.Search(len(entries), func(i int) bool { return entries[i].name >= part })
+		if i == len(entries) || entries[i].name != part {
+			return treeEntry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		entry = entries[i]
+	}
+	return entry, nil
+}
+
+// resolve finds the tree entry for a path, following symlinks in the tree
+func (f *FS) resolve(op, name string) (treeEntry, string, error) {
+	for hops := 0; hops < maxLinkHops; hops++ {
+		entry, err := f.lookup(op, name)
+		if err != nil {
+			return treeEntry{}, "", err
+		}
+		if entry.mode != modeSymlink {
+			return entry, name, nil
+		}
+
+		target, err := f.blob(entry.hash)
+		if err != nil {
+			return treeEntry{}, "", &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		link := string(target)
+		if !strings.HasPrefix(link, "/") {
+			link = path.Join(path.Dir(name), link)
+		}
+		name = strings.TrimPrefix(path.Clean("/"+link), "/")
+		if name == "" {
+			name = "."
+		}
+	}
+	return treeEntry{}, "", &fs.PathError{Op: op, Path: name, Err: errors.New("too many levels of symbolic links")}
+}
+
+// blob reads the contents of a blob
+func (f *FS) blob(h Hash) ([]byte, error) {
+	typ, data, err := f.repo.readObject(h)
+	if err != nil {
+		return nil, err
+	}
+	if typ != typeBlob {
+		return nil, fmt.Errorf("object %s is not a blob", h)
+	}
+	return data, nil
+}
+
+// info describes a tree entry, reading the size of blobs from their headers
+func (f *FS) info(entry treeEntry) (fs.FileInfo, error) {
+	fi := &fileInfo{name: entry.name, modTime: f.modTime}
+	switch entry.mode {
+	case modeTree, modeGitlink:
+		fi.mode = fs.ModeDir | 0755
+	case modeSymlink:
+		fi.mode = fs.ModeSymlink | 0777
+	case modeExec:
+		fi.mode = 0755
+	default:
+		fi.mode = fs.FileMode(entry.mode & 0o777)
+	}
+
+	if entry.mode != modeTree && entry.mode != modeGitlink {
+		size, err := f.repo.objectSize(entry.hash)
+		if err != nil {
+			return nil, err
+		}
+		fi.size = size
+	}
+	return fi, nil
+}
+
+// Open opens a file or directory of the tree
+func (f *FS) Open(name string) (fs.File, error) {
+	entry, resolved, err := f.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.info(entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &openFile{fsys: f, entry: entry, info: info, name: resolved}, nil
+}
+
+// Stat describes a file, following symlinks
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	entry, _, err := f.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return f.info(entry)
+}
+
+// Lstat describes a file without following symlinks
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	entry, err := f.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return f.info(entry)
+}
+
+// ReadLink returns the target of a symlink
+func (f *FS) ReadLink(name string) (string, error) {
+	entry, err := f.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if entry.mode != modeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	target, err := f.blob(entry.hash)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return string(target), nil
+}
+
+// fileInfo describes an entry of the tree
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+// dirEntry describes an entry while reading a directory, reading its size
+// only when asked for
+type dirEntry struct {
+	fsys  *FS
+	entry treeEntry
+}
+
+func (d dirEntry) Name() string { return d.entry.name }
+func (d dirEntry) IsDir() bool  { return d.entry.mode == modeTree || d.entry.mode == modeGitlink }
+func (d dirEntry) Type() fs.FileMode {
+	switch {
+	case d.IsDir():
+		return fs.ModeDir
+	case d.entry.mode == modeSymlink:
+		return fs.ModeSymlink
+	}
+	return 0
+}
+func (d dirEntry) Info() (fs.FileInfo, error) { return d.fsys.info(d.entry) }
+
+// openFile is an open file or directory of the tree
+type openFile struct {
+	fsys    *FS
+	entry   treeEntry
+	info    fs.FileInfo
+	name    string
+	reader  *bytes.Reader
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (o *openFile) Stat() (fs.FileInfo, error) { return o.info, nil }
+func (o *openFile) Close() error               { return nil }
+
+// Read reads the contents of a blob
+func (o *openFile) Read(p []byte) (int, error) {
+	if o.info.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: o.name, Err: errors.New("is a directory")}
+	}
+	if o.reader == nil {
+		data, err := o.fsys.blob(o.entry.hash)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: o.name, Err: err}
+		}
+		o.reader = bytes.NewReader(data)
+	}
+	return o.reader.Read(p)
+}
+
+// ReadDir reads the next n entries of a directory, or all remaining ones
+// when n is zero or less
+func (o *openFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	if !o.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: o.name, Err: errors.New("not a directory")}
+	}
+	if o.entries == nil {
+		o.entries = []fs.DirEntry{}
+		// Submodule commits are not in this repository, so they list empty
+		if o.entry.mode == modeTree {
+			entries, err := o.fsys.readTree(o.entry.hash)
+			if err != nil {
+				return nil, &fs.PathError{Op: "readdir", Path: o.name, Err: err}
+			}
+			for _, entry := range entries {
+				o.entries = append(o.entries, dirEntry{fsys: o.fsys, entry: entry})
+			}
+		}
+	}
+
+	remaining := o.entries[o.offset:]
+	if count <= 0 {
+		o.offset = len(o.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	o.offset += count
+	return remaining[:count], nil
+}
+
+// ReadDir lists a directory of the tree sorted by name
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return file.(*openFile).ReadDir(-1)
+}
@@ -0,0 +1,545 @@
+package gitrev
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go-ls-commands/dirsize"
+)
+
+// fixture is a repository written object by object, so tests run without
+// the git command
+type fixture struct {
+	t      *testing.T
+	gitDir string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	gitDir := filepath.Join(t.TempDir(), ".git")
+	for _, dir := range []string{"objects/pack", "refs/heads", "refs/tags"} {
+		if err := os.MkdirAll(filepath.Join(gitDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	return &fixture{t: t, gitDir: gitDir}
+}
+
+// hashObject returns the name git gives an object
+func hashObject(typ string, data []byte) Hash {
+	return Hash(sha1.Sum(append([]byte(fmt.Sprintf("%s %d\x00", typ, len(data))), data...)))
+}
+
+func compress(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+// loose stores a loose object
+func (f *fixture) loose(typ string, data []byte) Hash {
+	f.t.Helper()
+	h := hashObject(typ, data)
+	path := filepath.Join(f.gitDir, "objects", h.String()[:2], h.String()[2:])
+	raw := append([]byte(fmt.Sprintf("%s %d\x00", typ, len(data))), data...)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		f.t.Fatalf("Failed to create object directory: %v", err)
+	}
+	if err := os.WriteFile(path, compress(raw), 0644); err != nil {
+		f.t.Fatalf("Failed to write object: %v", err)
+	}
+	return h
+}
+
+func (f *fixture) write(name, content string) {
+	f.t.Helper()
+	if err := os.WriteFile(filepath.Join(f.gitDir, name), []byte(content), 0644); err != nil {
+		f.t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+type treeItem struct {
+	mode string
+	name string
+	hash Hash
+}
+
+func tree(items ...treeItem) []byte {
+	var buf bytes.Buffer
+	for _, item := range items {
+		fmt.Fprintf(&buf, "%s %s\x00", item.mode, item.name)
+		buf.Write(item.hash[:])
+	}
+	return buf.Bytes()
+}
+
+func commitData(tree Hash, when int64, parents ...Hash) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "tree %s\n", tree)
+	for _, p := range parents {
+		fmt.Fprintf(&buf, "parent %s\n", p)
+	}
+	fmt.Fprintf(&buf, "author A <a@example.com> %d +0000\n", when)
+	fmt.Fprintf(&buf, "committer A <a@example.com> %d +0200\n\nmessage\n", when)
+	return buf.Bytes()
+}
+
+// packEntry is an object to store in a pack, either whole or as a delta
+type packEntry struct {
+	hash     Hash
+	typ      int
+	data     []byte
+	ofsBase  int // index of an earlier entry for an offset delta, or -1
+	refBase  *Hash
+	packSize int // size recorded in the entry header, when not len(data)
+}
+
+// entryHeaderBytes encodes the type and size of a pack entry
+func entryHeaderBytes(typ, size int) []byte {
+	b := []byte{byte(typ<<4) | byte(size&15)}
+	size >>= 4
+	for size > 0 {
+		b[len(b)-1] |= 0x80
+		b = append(b, byte(size&0x7f))
+		size >>= 7
+	}
+	return b
+}
+
+// offsetBytes encodes the distance back to the base of an offset delta
+func offsetBytes(distance int64) []byte {
+	b := []byte{byte(distance & 0x7f)}
+	for distance >>= 7; distance > 0; distance >>= 7 {
+		distance--
+		b = append([]byte{byte(0x80 | distance&0x7f)}, b...)
+	}
+	return b
+}
+
+func varint(n int) []byte {
+	var b []byte
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// delta builds a delta copying base[offset:offset+size] and appending insert
+func delta(base []byte, offset, size int, insert string) []byte {
+	d := append(varint(len(base)), varint(size+len(insert))...)
+	d = append(d, 0x80|0x01|0x10, byte(offset), byte(size))
+	d = append(d, byte(len(insert)))
+	return append(d, insert...)
+}
+
+// pack stores the entries in a pack with a version 2 index
+func (f *fixture) pack(entries []packEntry) {
+	f.t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("PACK")
+	_ = binary.Write(&buf, binary.BigEndian, uint32(2))
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(entries)))
+
+	offsets := make([]int64, len(entries))
+	for i, e := range entries {
+		offsets[i] = int64(buf.Len())
+		size := len(e.data)
+		if e.packSize != 0 {
+			size = e.packSize
+		}
+		switch {
+		case e.ofsBase >= 0:
+			buf.Write(entryHeaderBytes(typeOfsDelta, size))
+			buf.Write(offsetBytes(offsets[i] - offsets[e.ofsBase]))
+		case e.refBase != nil:
+			buf.Write(entryHeaderBytes(typeRefDelta, size))
+			buf.Write(e.refBase[:])
+		default:
+			buf.Write(entryHeaderBytes(e.typ, size))
+		}
+		buf.Write(compress(e.data))
+	}
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return bytes.Compare(entries[order[a]].hash[:], entries[order[b]].hash[:]) < 0
+	})
+
+	var idx bytes.Buffer
+	idx.WriteString("\377tOc")
+	_ = binary.Write(&idx, binary.BigEndian, uint32(2))
+	for b := 0; b < 256; b++ {
+		count := 0
+		for _, e := range entries {
+			if int(e.hash[0]) <= b {
+				count++
+			}
+		}
+		_ = binary.Write(&idx, binary.BigEndian, uint32(count))
+	}
+	for _, i := range order {
+		idx.Write(entries[i].hash[:])
+	}
+	for range order {
+		_ = binary.Write(&idx, binary.BigEndian, uint32(0)) // CRCs are not checked
+	}
+	for _, i := range order {
+		_ = binary.Write(&idx, binary.BigEndian, uint32(offsets[i]))
+	}
+
+	name := filepath.Join(f.gitDir, "objects", "pack", "pack-test")
+	if err := os.WriteFile(name+".pack", buf.Bytes(), 0644); err != nil {
+		f.t.Fatalf("Failed to write pack: %v", err)
+	}
+	if err := os.WriteFile(name+".idx", idx.Bytes(), 0644); err != nil {
+		f.t.Fatalf("Failed to write index: %v", err)
+	}
+}
+
+// repoFixture holds the names of the objects in the test repository
+type repoFixture struct {
+	gitDir                    string
+	first, second, side, head Hash
+	tag                       Hash
+}
+
+const (
+	hello    = "hello, world\n"
+	baseText = "the quick brown fox jumps over the lazy dog\n"
+)
+
+// buildRepo writes a repository with this history:
+//
+//	first --- second --- head (merge)
+//	   \                /
+//	    ------ side ----
+//
+// head holds loose and packed blobs, including offset and ref deltas, an
+// executable, a symlink, a subdirectory and a submodule. The tag v1 is an
+// annotated tag stored in packed-refs.
+func buildRepo(t *testing.T) repoFixture {
+	f := newFixture(t)
+	var r repoFixture
+	r.gitDir = f.gitDir
+
+	helloBlob := f.loose("blob", []byte(hello))
+	script := f.loose("blob", []byte("#!/bin/sh\n"))
+	link := f.loose("blob", []byte("hello.txt"))
+
+	// The notes are an offset delta on a packed base, and the changelog a
+	// ref delta on the loose hello blob
+	notesText := baseText[4:19] + "!\n"
+	changesText := hello[:5] + " again\n"
+	notes := hashObject("blob", []byte(notesText))
+	changes := hashObject("blob", []byte(changesText))
+	baseBlob := hashObject("blob", []byte(baseText))
+	f.pack([]packEntry{
+		{hash: baseBlob, typ: typeBlob, data: []byte(baseText), ofsBase: -1},
+		{hash: notes, data: delta([]byte(baseText), 4, 15, "!\n"), ofsBase: 0},
+		{hash: changes, data: delta([]byte(hello), 0, 5, " again\n"), ofsBase: -1, refBase: &helloBlob},
+	})
+
+	sub := f.loose("tree", tree(
+		treeItem{"100644", "base.txt", baseBlob},
+		treeItem{"100644", "notes.md", notes},
+	))
+	firstTree := f.loose("tree", tree(treeItem{"100644", "hello.txt", helloBlob}))
+	headTree := f.loose("tree", tree(
+		treeItem{"100644", "CHANGES", changes},
+		treeItem{"100644", "hello.txt", helloBlob},
+		treeItem{"120000", "link", link},
+		treeItem{"160000", "module", Hash{0xab}},
+		treeItem{"100755", "run.sh", script},
+		treeItem{"40000", "sub", sub},
+	))
+
+	r.first = f.loose("commit", commitData(firstTree, 1700000000))
+	r.second = f.loose("commit", commitData(firstTree, 1700000100, r.first))
+	r.side = f.loose("commit", commitData(firstTree, 1700000200, r.first))
+	r.head = f.loose("commit", commitData(headTree, 1700000300, r.second, r.side))
+
+	r.tag = f.loose("tag", []byte(fmt.Sprintf("object %s\ntype commit\ntag v1\n\nrelease\n", r.first)))
+	f.write("HEAD", "ref: refs/heads/main\n")
+	f.write("refs/heads/main", r.head.String()+"\n")
+	f.write("packed-refs", fmt.Sprintf("# pack-refs with: peeled\n%s refs/tags/v1\n^%s\n%s refs/heads/side\n",
+		r.tag, r.first, r.side))
+	return r
+}
+
+func TestResolve(t *testing.T) {
+	r := buildRepo(t)
+	repo, err := Open(r.gitDir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	tests := []struct {
+		rev  string
+		want Hash
+	}{
+		{"HEAD", r.head},
+		{"@", r.head},
+		{"main", r.head},
+		{"refs/heads/main", r.head},
+		{"side", r.side},
+		{"HEAD~1", r.second},
+		{"HEAD^", r.second},
+		{"HEAD^2", r.side},
+		{"HEAD^0", r.head},
+		{"HEAD~2", r.first},
+		{"HEAD^2~1", r.first},
+		{"v1", r.first},
+		{"tags/v1", r.first},
+		{r.second.String(), r.second},
+		{r.second.String()[:10], r.second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rev, func(t *testing.T) {
+			got, err := repo.Resolve(tt.rev)
+			if err != nil {
+				t.Fatalf("Resolve(%q) error = %v", tt.rev, err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %s, want %s", tt.rev, got, tt.want)
+			}
+		})
+	}
+
+	for _, rev := range []string{"nope", "HEAD~3", "HEAD^3", "HEAD@{1}", "deadbeef"} {
+		if _, err := repo.Resolve(rev); err == nil {
+			t.Errorf("Resolve(%q) expected error", rev)
+		}
+	}
+}
+
+func TestTreeFS(t *testing.T) {
+	r := buildRepo(t)
+	repo, err := Open(r.gitDir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	fsys, err := repo.TreeFS("HEAD")
+	if err != nil {
+		t.Fatalf("TreeFS() error = %v", err)
+	}
+
+	if err := fstest.TestFS(fsys, "hello.txt", "CHANGES", "run.sh", "sub/notes.md", "module"); err != nil {
+		t.Errorf("fstest.TestFS() error = %v", err)
+	}
+
+	modTime := time.Unix(1700000300, 0)
+	tests := []struct {
+		name string
+		mode fs.FileMode
+		size int64
+	}{
+		{".", fs.ModeDir | 0755, 0},
+		{"hello.txt", 0644, int64(len(hello))},
+		{"CHANGES", 0644, int64(len(hello[:5] + " again\n"))},
+		{"run.sh", 0755, 10},
+		{"link", fs.ModeSymlink | 0777, 9},
+		{"module", fs.ModeDir | 0755, 0},
+		{"sub", fs.ModeDir | 0755, 0},
+		{"sub/base.txt", 0644, int64(len(baseText))},
+		{"sub/notes.md", 0644, 17},
+	}
+	for _, tt := range tests {
+		info, err := fsys.Lstat(tt.name)
+		if err != nil {
+			t.Errorf("Lstat(%q) error = %v", tt.name, err)
+			continue
+		}
+		if info.Mode() != tt.mode || info.Size() != tt.size || !info.ModTime().Equal(modTime) {
+			t.Errorf("Lstat(%q) = %v %d %v, want %v %d %v", tt.name,
+				info.Mode(), info.Size(), info.ModTime(), tt.mode, tt.size, modTime)
+		}
+	}
+
+	contents := map[string]string{
+		"hello.txt":    hello,
+		"CHANGES":      "hello again\n",
+		"sub/notes.md": "quick brown fox!\n",
+		"link":         hello, // read through the symlink
+	}
+	for name, want := range contents {
+		got, err := fs.ReadFile(fsys, name)
+		if err != nil || string(got) != want {
+			t.Errorf("ReadFile(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+
+	if target, err := fsys.ReadLink("link"); err != nil || target != "hello.txt" {
+		t.Errorf("ReadLink(link) = %q, %v", target, err)
+	}
+	if info, err := fsys.Stat("link"); err != nil || info.Mode() != 0644 {
+		t.Errorf("Stat(link) should follow the symlink, got %v, %v", info, err)
+	}
+	if entries, err := fsys.ReadDir("module"); err != nil || len(entries) != 0 {
+		t.Errorf("ReadDir(module) = %v, %v, want an empty submodule", entries, err)
+	}
+	if _, err := fsys.Lstat("sub/missing"); err == nil {
+		t.Errorf("Lstat(sub/missing) expected error")
+	}
+
+	older, err := repo.TreeFS("v1")
+	if err != nil {
+		t.Fatalf("TreeFS(v1) error = %v", err)
+	}
+	entries, err := older.ReadDir(".")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "hello.txt" {
+		t.Errorf("ReadDir(.) at v1 = %v, %v", entries, err)
+	}
+}
+
+// TestTreeFSConcurrent checks that a tree can be read from several
+// goroutines at once, as --dir-size does with --git-rev. Run with -race.
+func TestTreeFSConcurrent(t *testing.T) {
+	r := buildRepo(t)
+	repo, err := Open(r.gitDir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	fsys, err := repo.TreeFS("HEAD")
+	if err != nil {
+		t.Fatalf("TreeFS() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	sizes := make([]int64, 8)
+	for i := range sizes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sizes[i], _ = dirsize.New(fsys, false).Size(context.Background(), ".")
+		}(i)
+	}
+	wg.Wait()
+
+	for i, size := range sizes {
+		if size != sizes[0] || size == 0 {
+			t.Errorf("Size(.) in goroutine %d = %d, want %d", i, size, sizes[0])
+		}
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("0123456789")
+	tests := []struct {
+		name  string
+		delta []byte
+		want  string
+		ok    bool
+	}{
+		{"copy and insert", delta(base, 2, 3, "ab"), "234ab", true},
+		{"insert only", append([]byte{10, 3, 3}, "xyz"...), "xyz", true},
+		{"base size mismatch", []byte{9, 1, 1, 'x'}, "", false},
+		{"copy out of range", []byte{10, 5, 0x91, 8, 5}, "", false},
+		{"truncated insert", []byte{10, 3, 3, 'x'}, "", false},
+		{"result size mismatch", []byte{10, 4, 1, 'x'}, "", false},
+		{"zero opcode", []byte{10, 0, 0}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyDelta(base, tt.delta)
+			if (err == nil) != tt.ok {
+				t.Fatalf("applyDelta() error = %v, want ok %v", err, tt.ok)
+			}
+			if tt.ok && string(got) != tt.want {
+				t.Errorf("applyDelta() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCorruptPack checks that ref delta cycles and entries claiming more
+// data than they hold are reported as errors
+func TestCorruptPack(t *testing.T) {
+	f := newFixture(t)
+	a := Hash{0x01}
+	b := Hash{0x02}
+	huge := Hash{0x03}
+	short := Hash{0x04}
+	f.pack([]packEntry{
+		{hash: a, data: delta([]byte("x"), 0, 1, ""), ofsBase: -1, refBase: &b},
+		{hash: b, data: delta([]byte("x"), 0, 1, ""), ofsBase: -1, refBase: &a},
+		{hash: huge, typ: typeBlob, data: []byte("tiny"), ofsBase: -1, packSize: 1 << 50},
+		{hash: short, typ: typeBlob, data: []byte("tiny"), ofsBase: -1, packSize: 5},
+	})
+	f.write("HEAD", "ref: refs/heads/main\n")
+	repo, err := Open(f.gitDir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	for _, h := range []Hash{a, b, huge, short} {
+		if _, _, err := repo.readObject(h); err == nil {
+			t.Errorf("readObject(%s) expected an error", h)
+		}
+	}
+}
+
+func TestOffsetEncoding(t *testing.T) {
+	// Offsets decode the way the header writer in this file encodes them
+	for _, distance := range []int64{1, 127, 128, 16511, 16512, 1 << 30} {
+		f, err := os.CreateTemp(t.TempDir(), "entry")
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := append(entryHeaderBytes(typeOfsDelta, 300), offsetBytes(distance)...)
+		offset := distance + 5
+		if _, err := f.WriteAt(header, offset); err != nil {
+			t.Fatal(err)
+		}
+		h, err := readEntryHeader(f, offset)
+		f.Close()
+		if err != nil || h.baseOffset != offset-distance || h.size != 300 {
+			t.Errorf("distance %d decoded as %+v, %v", distance, h, err)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	r := buildRepo(t)
+	root := filepath.Dir(r.gitDir)
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	gotRoot, gotGitDir, err := Discover(nested)
+	if err != nil || gotRoot != root || gotGitDir != r.gitDir {
+		t.Errorf("Discover() = %q, %q, %v, want %q, %q", gotRoot, gotGitDir, err, root, r.gitDir)
+	}
+
+	// A worktree points at its git directory through a .git file
+	worktree := t.TempDir()
+	if err := os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+r.gitDir+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, gotGitDir, err := Discover(worktree); err != nil || gotGitDir != r.gitDir {
+		t.Errorf("Discover(worktree) = %q, %v, want %q", gotGitDir, err, r.gitDir)
+	}
+}
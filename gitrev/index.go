@@ -0,0 +1,142 @@
+package gitrev
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// indexEntry is a file recorded in the index, with the stat data git uses
+// to tell whether the working tree copy may have changed
+type indexEntry struct {
+	path  string
+	mode  uint32
+	hash  Hash
+	stage int
+	mtime time.Time
+	size  uint32
+	ino   uint32
+}
+
+// index is the parsed contents of a git index file
+type index struct {
+	entries []indexEntry
+	modTime time.Time
+}
+
+// readIndex reads the index of the repository. A repository without an
+// index, such as a fresh one, has no entries.
+func (r *Repo) readIndex() (*index, error) {
+	name := filepath.Join(r.gitDir, "index")
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return &index{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := parseIndexFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if info, err := os.Stat(name); err == nil {
+		idx.modTime = info.ModTime()
+	}
+	return idx, nil
+}
+
+// parseIndexFile parses a version 2, 3 or 4 index. Extensions after the
+// entries are not needed and left unread.
+func parseIndexFile(data []byte) (*index, error) {
+	if len(data) < 12 || !bytes.Equal(data[:4], []byte("DIRC")) {
+		return nil, fmt.Errorf("not an index file")
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("unsupported index version %d", version)
+	}
+	count := int(binary.BigEndian.Uint32(data[8:12]))
+
+	idx := &index{entries: make([]indexEntry, 0, count)}
+	pos := 12
+	previous := ""
+	for i := 0; i < count; i++ {
+		// 40 bytes of stat data and mode, the object name and the flags
+		if len(data) < pos+62 {
+			return nil, fmt.Errorf("truncated index")
+		}
+		entry := data[pos:]
+		field := func(n int) uint32 { return binary.BigEndian.Uint32(entry[n*4:]) }
+
+		e := indexEntry{
+			mtime: time.Unix(int64(field(2)), int64(field(3))),
+			ino:   field(5),
+			mode:  field(6),
+			size:  field(9),
+		}
+		copy(e.hash[:], entry[40:60])
+		flags := binary.BigEndian.Uint16(entry[60:62])
+		e.stage = int(flags>>12) & 3
+
+		headerLen := 62
+		if version >= 3 && flags&0x4000 != 0 {
+			headerLen += 2 // extended flags
+		}
+		if len(data) < pos+headerLen {
+			return nil, fmt.Errorf("truncated index")
+		}
+		rest := data[pos+headerLen:]
+
+		if version == 4 {
+			// The name drops a number of bytes from the end of the previous
+			// name and appends a NUL terminated suffix, with no padding
+			strip, n := indexVarint(rest)
+			if n == 0 || strip > len(previous) {
+				return nil, fmt.Errorf("invalid path compression")
+			}
+			end := bytes.IndexByte(rest[n:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("truncated index")
+			}
+			e.path = previous[:len(previous)-strip] + string(rest[n:n+end])
+			pos += headerLen + n + end + 1
+		} else {
+			end := bytes.IndexByte(rest, 0)
+			if end < 0 {
+				return nil, fmt.Errorf("truncated index")
+			}
+			e.path = string(rest[:end])
+
+			// Entries are padded with 1 to 8 NULs to a multiple of 8 bytes
+			pos += (headerLen + end + 8) &^ 7
+		}
+
+		previous = e.path
+		idx.entries = append(idx.entries, e)
+	}
+	return idx, nil
+}
+
+// indexVarint reads the offset style number used by version 4 indexes,
+// returning the number of bytes read or zero if the data ends first
+func indexVarint(data []byte) (int, int) {
+	value := 0
+	for i, c := range data {
+		if i >= 9 {
+			return 0, 0
+		}
+		if i == 0 {
+			value = int(c & 0x7f)
+		} else {
+			value = (value+1)<<7 | int(c&0x7f)
+		}
+		if c&0x80 == 0 {
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,549 @@
+package gitrev
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Object types as stored in loose object headers and pack entries
+const (
+	typeCommit   = 1
+	typeTree     = 2
+	typeBlob     = 3
+	typeTag      = 4
+	typeOfsDelta = 6
+	typeRefDelta = 7
+)
+
+// maxDeltaChain bounds how many deltas are applied to rebuild one object
+const maxDeltaChain = 5000
+
+// typeNames maps loose object header names to object types
+var typeNames = map[string]int{
+	"commit": typeCommit,
+	"tree":   typeTree,
+	"blob":   typeBlob,
+	"tag":    typeTag,
+}
+
+// ErrNotFound is returned when an object is in neither the loose object
+// directories nor any pack
+var ErrNotFound = errors.New("object not found")
+
+// Hash is the SHA-1 name of a git object
+type Hash [20]byte
+
+// ParseHash parses a full 40 character hexadecimal object name
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	if len(s) != 40 {
+		return h, fmt.Errorf("invalid object name '%s'", s)
+	}
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		return h, fmt.Errorf("invalid object name '%s'", s)
+	}
+	return h, nil
+}
+
+// String returns the hexadecimal form of the hash
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// readObject returns the type and contents of an object
+func (r *Repo) readObject(h Hash) (int, []byte, error) {
+	return r.readObjectAt(h, 0)
+}
+
+// readObjectAt reads an object needed as the base of a delta depth deltas
+// deep, so that chains of ref deltas are bounded like offset deltas
+func (r *Repo) readObjectAt(h Hash, depth int) (int, []byte, error) {
+	if typ, data, err := r.readLoose(h); err == nil {
+		return typ, data, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, nil, err
+	}
+
+	for _, p := range r.loadPacks() {
+		if offset, ok := p.find(h); ok {
+			return p.readAt(r, offset, depth)
+		}
+	}
+	return 0, nil, fmt.Errorf("%s: %w", h, ErrNotFound)
+}
+
+// objectSize returns the size of an object's contents without rebuilding
+// it when the size is recorded in a header
+func (r *Repo) objectSize(h Hash) (int64, error) {
+	if size, err := r.looseSize(h); err == nil {
+		return size, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+
+	for _, p := range r.loadPacks() {
+		if offset, ok := p.find(h); ok {
+			return p.sizeAt(offset)
+		}
+	}
+	return 0, fmt.Errorf("%s: %w", h, ErrNotFound)
+}
+
+// loosePath returns where a loose object is stored
+func (r *Repo) loosePath(h Hash) string {
+	name := h.String()
+	return filepath.Join(r.objectsDir, name[:2], name[2:])
+}
+
+// readLoose reads a zlib compressed loose object
+func (r *Repo) readLoose(h Hash) (int, []byte, error) {
+	f, err := os.Open(r.loosePath(h))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %v", h, err)
+	}
+	defer zr.Close()
+
+	br := bufio.NewReader(zr)
+	typ, size, err := readLooseHeader(br)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %v", h, err)
+	}
+
+	data, err := readSized(br, size)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %v", h, err)
+	}
+	return typ, data, nil
+}
+
+// looseSize reads only the header of a loose object
+func (r *Repo) looseSize(h Hash) (int64, error) {
+	f, err := os.Open(r.loosePath(h))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", h, err)
+	}
+	defer zr.Close()
+
+	_, size, err := readLooseHeader(bufio.NewReader(zr))
+	return size, err
+}
+
+// readLooseHeader parses the "type size\0" header of a loose object
+func readLooseHeader(br *bufio.Reader) (int, int64, error) {
+	header, err := br.ReadString(0)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad object header")
+	}
+
+	name, sizeStr, ok := strings.Cut(strings.TrimSuffix(header, "\x00"), " ")
+	typ, known := typeNames[name]
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if !ok || !known || err != nil || size < 0 {
+		return 0, 0, fmt.Errorf("bad object header %q", header)
+	}
+	return typ, size, nil
+}
+
+// pack is a packfile along with its index
+type pack struct {
+	path    string
+	names   []Hash
+	offsets []int64
+}
+
+// loadPacks reads the index of every pack once. It is safe to call from
+// several goroutines.
+func (r *Repo) loadPacks() []*pack {
+	r.packsOnce.Do(func() {
+		idxFiles, _ := filepath.Glob(filepath.Join(r.objectsDir, "pack", "*.idx"))
+		sort.Strings(idxFiles)
+		for _, idxFile := range idxFiles {
+			data, err := os.ReadFile(idxFile)
+			if err != nil {
+				continue
+			}
+			p, err := parseIndex(data)
+			if err != nil {
+				continue
+			}
+			p.path = strings.TrimSuffix(idxFile, ".idx") + ".pack"
+			r.packs = append(r.packs, p)
+		}
+	})
+	return r.packs
+}
+
+// parseIndex parses a version 1 or version 2 pack index
+func parseIndex(data []byte) (*pack, error) {
+	p := &pack{}
+
+	if len(data) >= 8 && bytes.Equal(data[:4], []byte("\377tOc")) {
+		if binary.BigEndian.Uint32(data[4:8]) != 2 {
+			return nil, fmt.Errorf("unsupported pack index version")
+		}
+		if len(data) < 8+256*4 {
+			return nil, fmt.Errorf("truncated pack index")
+		}
+		count := int(binary.BigEndian.Uint32(data[8+255*4:]))
+		namesStart := 8 + 256*4
+		offsetsStart := namesStart + count*20 + count*4
+		largeStart := offsetsStart + count*4
+		if len(data) < largeStart {
+			return nil, fmt.Errorf("truncated pack index")
+		}
+
+		p.names = make([]Hash, count)
+		p.offsets = make([]int64, count)
+		for i := 0; i < count; i++ {
+			copy(p.names[i][:], data[namesStart+i*20:])
+			offset := binary.BigEndian.Uint32(data[offsetsStart+i*4:])
+			if offset&0x80000000 != 0 {
+				// Offsets past 2GiB live in a separate table of 64-bit values
+				pos := largeStart + int(offset&0x7fffffff)*8
+				if len(data) < pos+8 {
+					return nil, fmt.Errorf("truncated pack index")
+				}
+				p.offsets[i] = int64(binary.BigEndian.Uint64(data[pos:]))
+			} else {
+				p.offsets[i] = int64(offset)
+			}
+		}
+		return p, nil
+	}
+
+	// Version 1 has a fan-out table followed by offset and name pairs
+	if len(data) < 256*4 {
+		return nil, fmt.Errorf("truncated pack index")
+	}
+	count := int(binary.BigEndian.Uint32(data[255*4:]))
+	if len(data) < 256*4+count*24 {
+		return nil, fmt.Errorf("truncated pack index")
+	}
+	p.names = make([]Hash, count)
+	p.offsets = make([]int64, count)
+	for i := 0; i < count; i++ {
+		entry := data[256*4+i*24:]
+		p.offsets[i] = int64(binary.BigEndian.Uint32(entry))
+		copy(p.names[i][:], entry[4:24])
+	}
+	return p, nil
+}
+
+// find returns the offset of an object in the pack
+func (p *pack) find(h Hash) (int64, bool) {
+	i := sort.Search(len(p.names), func(i int) bool {
+		return bytes.Compare(p.names[i][:], h[:]) >= 0
+	})
+	if i < len(p.names) && p.names[i] == h {
+		return p.offsets[i], true
+	}
+	return 0, false
+}
+
+// entryHeader describes a pack entry up to the start of its compressed data
+type entryHeader struct {
+	typ        int
+	size       int64
+	baseOffset int64
+	baseHash   Hash
+	dataOffset int64
+}
+
+// readEntryHeader parses the type and size of a pack entry and, for
+// deltas, the location of the base object
+func readEntryHeader(f *os.File, offset int64) (entryHeader, error) {
+	buf := make([]byte, 64)
+	n, err := f.ReadAt(buf, offset)
+	if n == 0 {
+		return entryHeader{}, fmt.Errorf("reading pack entry: %v", err)
+	}
+	buf = buf[:n]
+
+	pos := 0
+	next := func() (byte, error) {
+		if pos >= len(buf) {
+			return 0, fmt.Errorf("truncated pack entry")
+		}
+		pos++
+		return buf[pos-1], nil
+	}
+
+	c, err := next()
+	if err != nil {
+		return entryHeader{}, err
+	}
+	h := entryHeader{typ: int(c>>4) & 7, size: int64(c & 15)}
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = next(); err != nil {
+			return entryHeader{}, err
+		}
+		h.size |= int64(c&0x7f) << shift
+	}
+
+	switch h.typ {
+	case typeOfsDelta:
+		if c, err = next(); err != nil {
+			return entryHeader{}, err
+		}
+		distance := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = next(); err != nil {
+				return entryHeader{}, err
+			}
+			distance = (distance+1)<<7 | int64(c&0x7f)
+		}
+		h.baseOffset = offset - distance
+	case typeRefDelta:
+		if len(buf) < pos+20 {
+			return entryHeader{}, fmt.Errorf("truncated pack entry")
+		}
+		copy(h.baseHash[:], buf[pos:pos+20])
+		pos += 20
+	}
+
+	h.dataOffset = offset + int64(pos)
+	return h, nil
+}
+
+// readSized reads the size bytes an object header announces. The size is
+// not trusted to allocate up front, so a corrupt header cannot make a small
+// object claim any amount of memory.
+func readSized(r io.Reader, size int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, size))
+	if err == nil && int64(len(data)) != size {
+		err = io.ErrUnexpectedEOF
+	}
+	return data, err
+}
+
+// inflate decompresses the data of a pack entry, or only its first limit
+// bytes when limit is not negative
+func inflate(f *os.File, h entryHeader, limit int64) ([]byte, error) {
+	zr, err := zlib.NewReader(io.NewSectionReader(f, h.dataOffset, 1<<62))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	if limit >= 0 {
+		return io.ReadAll(io.LimitReader(zr, limit))
+	}
+	return readSized(zr, h.size)
+}
+
+// readAt rebuilds the object stored at an offset of the pack, applying
+// deltas on top of their bases
+func (p *pack) readAt(r *Repo, offset int64, depth int) (int, []byte, error) {
+	if depth > maxDeltaChain {
+		return 0, nil, fmt.Errorf("delta chain too long in %s", p.path)
+	}
+
+	f, err := os.Open(p.path)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// The pack is closed before reading the base, so that long chains do
+	// not hold a file open for each delta
+	h, err := readEntryHeader(f, offset)
+	if err != nil {
+		f.Close()
+		return 0, nil, fmt.Errorf("%s: %v", p.path, err)
+	}
+	data, err := inflate(f, h, -1)
+	f.Close()
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %v", p.path, err)
+	}
+
+	var baseType int
+	var base []byte
+	switch h.typ {
+	case typeCommit, typeTree, typeBlob, typeTag:
+		return h.typ, data, nil
+	case typeOfsDelta:
+		baseType, base, err = p.readAt(r, h.baseOffset, depth+1)
+	case typeRefDelta:
+		baseType, base, err = r.readObjectAt(h.baseHash, depth+1)
+	default:
+		return 0, nil, fmt.Errorf("%s: unknown pack entry type %d", p.path, h.typ)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result, err := applyDelta(base, data)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %v", p.path, err)
+	}
+	return baseType, result, nil
+}
+
+// sizeAt returns the size of the object stored at an offset of the pack,
+// reading the result size from the start of a delta instead of applying it
+func (p *pack) sizeAt(offset int64) (int64, error) {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	h, err := readEntryHeader(f, offset)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", p.path, err)
+	}
+	if h.typ != typeOfsDelta && h.typ != typeRefDelta {
+		return h.size, nil
+	}
+
+	// A delta starts with two varints, the base size and the result size
+	start, err := inflate(f, h, 20)
+	if err != nil && len(start) == 0 {
+		return 0, fmt.Errorf("%s: %v", p.path, err)
+	}
+	_, n := deltaVarint(start)
+	if n == 0 {
+		return 0, fmt.Errorf("%s: truncated delta", p.path)
+	}
+	size, m := deltaVarint(start[n:])
+	if m == 0 {
+		return 0, fmt.Errorf("%s: truncated delta", p.path)
+	}
+	return int64(size), nil
+}
+
+// deltaVarint reads a little-endian base 128 number used in delta headers,
+// returning the number of bytes read or zero if the data ends first
+func deltaVarint(data []byte) (uint64, int) {
+	var value uint64
+	for i, c := range data {
+		if i >= 10 {
+			return 0, 0
+		}
+		value |= uint64(c&0x7f) << (7 * i)
+		if c&0x80 == 0 {
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// applyDelta rebuilds an object from its base and a git delta, made of copy
+// instructions taking a range of the base and insert instructions carrying
+// literal bytes
+func applyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, n := deltaVarint(delta)
+	if n == 0 || baseSize != uint64(len(base)) {
+		return nil, fmt.Errorf("delta base size mismatch")
+	}
+	delta = delta[n:]
+
+	resultSize, n := deltaVarint(delta)
+	if n == 0 {
+		return nil, fmt.Errorf("truncated delta")
+	}
+	delta = delta[n:]
+
+	// The result size is only a hint, bounded by what the delta can build
+	// without repeating itself
+	result := make([]byte, 0, min(resultSize, uint64(len(base)+len(delta))))
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		switch {
+		case op&0x80 != 0:
+			// Copy: bits 0-3 select offset bytes, bits 4-6 select size bytes
+			var offset, size uint64
+			for i := 0; i < 7; i++ {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta copy")
+				}
+				if i < 4 {
+					offset |= uint64(delta[0]) << (8 * i)
+				} else {
+					size |= uint64(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, fmt.Errorf("delta copy out of range")
+			}
+			result = append(result, base[offset:offset+size]...)
+		case op != 0:
+			// Insert: the opcode is the number of literal bytes
+			if int(op) > len(delta) {
+				return nil, fmt.Errorf("truncated delta insert")
+			}
+			result = append(result, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, fmt.Errorf("invalid delta opcode 0")
+		}
+	}
+
+	if uint64(len(result)) != resultSize {
+		return nil, fmt.Errorf("delta result size mismatch")
+	}
+	return result, nil
+}
+
+// findAbbrev returns the only object whose name starts with a hexadecimal
+// prefix
+func (r *Repo) findAbbrev(prefix string) (Hash, error) {
+	prefix = strings.ToLower(prefix)
+	found := map[Hash]bool{}
+
+	names, _ := os.ReadDir(filepath.Join(r.objectsDir, prefix[:2]))
+	for _, entry := range names {
+		if strings.HasPrefix(prefix[:2]+entry.Name(), prefix) {
+			if h, err := ParseHash(prefix[:2] + entry.Name()); err == nil {
+				found[h] = true
+			}
+		}
+	}
+	for _, p := range r.loadPacks() {
+		for _, h := range p.names {
+			if strings.HasPrefix(h.String(), prefix) {
+				found[h] = true
+			}
+		}
+	}
+
+	if len(found) > 1 {
+		return Hash{}, fmt.Errorf("short object name '%s' is ambiguous", prefix)
+	}
+	for h := range found {
+		return h, nil
+	}
+	return Hash{}, fmt.Errorf("%s: %w", prefix, ErrNotFound)
+}
@@ -0,0 +1,312 @@
+package gitrev
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSymrefDepth bounds how many symbolic refs are followed
+const maxSymrefDepth = 10
+
+// Repo reads objects and refs straight from a .git directory
+type Repo struct {
+	gitDir     string
+	commonDir  string
+	objectsDir string
+
+	// packs holds the index of every pack, loaded once on first use
+	packs     []*pack
+	packsOnce sync.Once
+}
+
+// commit holds the parts of a commit needed to list its tree
+type commit struct {
+	tree    Hash
+	parents []Hash
+	time    time.Time
+}
+
+// Discover walks up from dir to the top of a working tree, returning it
+// along with its git directory. A .git file pointing elsewhere, as used by
+// worktrees and submodules, is followed.
+func Discover(dir string) (string, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				return dir, dotGit, nil
+			}
+			data, err := os.ReadFile(dotGit)
+			if err != nil {
+				return "", "", err
+			}
+			gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+			if !ok {
+				return "", "", fmt.Errorf("invalid gitfile format: %s", dotGit)
+			}
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(dir, gitDir)
+			}
+			return dir, gitDir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", fmt.Errorf("not a git repository (or any of the parent directories)")
+		}
+		dir = parent
+	}
+}
+
+// Open opens the repository stored in gitDir
+func Open(gitDir string) (*Repo, error) {
+	if _, err := os.Stat(filepath.Join(gitDir, "HEAD")); err != nil {
+		return nil, fmt.Errorf("not a git repository: %s", gitDir)
+	}
+
+	// Worktrees share objects and most refs with the main repository
+	commonDir := gitDir
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+
+	return &Repo{
+		gitDir:     gitDir,
+		commonDir:  commonDir,
+		objectsDir: filepath.Join(commonDir, "objects"),
+	}, nil
+}
+
+// Resolve turns a revision such as HEAD~3, main^2, v1.0 or an abbreviated
+// object name into the commit it names
+func (r *Repo) Resolve(rev string) (Hash, error) {
+	end := strings.IndexAny(rev, "~^")
+	if end < 0 {
+		end = len(rev)
+	}
+
+	h, err := r.resolveBase(rev[:end])
+	if err != nil {
+		return Hash{}, err
+	}
+	h, err = r.peel(h)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	// Apply ~N and ^N suffixes from left to right
+	suffix := rev[end:]
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+		if op != '~' && op != '^' {
+			return Hash{}, fmt.Errorf("unknown revision '%s'", rev)
+		}
+
+		digits := 0
+		for digits < len(suffix) && suffix[digits] >= '0' && suffix[digits] <= '9' {
+			digits++
+		}
+		n := 1
+		if digits > 0 {
+			n, _ = strconv.Atoi(suffix[:digits])
+		}
+		suffix = suffix[digits:]
+
+		if op == '~' {
+			for i := 0; i < n; i++ {
+				if h, err = r.parent(h, 1, rev); err != nil {
+					return Hash{}, err
+				}
+			}
+		} else if n > 0 {
+			// ^0 names the commit itself
+			if h, err = r.parent(h, n, rev); err != nil {
+				return Hash{}, err
+			}
+		}
+	}
+
+	return h, nil
+}
+
+// resolveBase resolves a ref name or object name without suffixes
+func (r *Repo) resolveBase(name string) (Hash, error) {
+	if name == "" || name == "@" {
+		name = "HEAD"
+	}
+
+	// Refs are tried in the order git rev-parse uses
+	candidates := []string{name, "refs/" + name, "refs/tags/" + name, "refs/heads/" + name,
+		"refs/remotes/" + name, "refs/remotes/" + name + "/HEAD"}
+	for _, ref := range candidates {
+		if h, ok := r.readRef(ref, 0); ok {
+			return h, nil
+		}
+	}
+
+	if len(name) >= 4 && len(name) <= 40 && isHex(name) {
+		if len(name) == 40 {
+			return ParseHash(name)
+		}
+		return r.findAbbrev(name)
+	}
+	return Hash{}, fmt.Errorf("unknown revision '%s'", name)
+}
+
+// readRef reads a loose or packed ref, following symbolic refs
+func (r *Repo) readRef(ref string, depth int) (Hash, bool) {
+	if depth > maxSymrefDepth || strings.Contains(ref, "..") {
+		return Hash{}, false
+	}
+
+	// HEAD and other pseudo refs belong to the worktree, the rest are shared
+	dir := r.commonDir
+	if !strings.HasPrefix(ref, "refs/") {
+		dir = r.gitDir
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref))); err == nil {
+		content := strings.TrimSpace(string(data))
+		if target, ok := strings.CutPrefix(content, "ref: "); ok {
+			return r.readRef(target, depth+1)
+		}
+		if h, err := ParseHash(content); err == nil {
+			return h, true
+		}
+		return Hash{}, false
+	}
+
+	if !strings.HasPrefix(ref, "refs/") {
+		return Hash{}, false
+	}
+	data, err := os.ReadFile(filepath.Join(r.commonDir, "packed-refs"))
+	if err != nil {
+		return Hash{}, false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		hash, name, ok := strings.Cut(line, " ")
+		if ok && name == ref {
+			h, err := ParseHash(hash)
+			return h, err == nil
+		}
+	}
+	return Hash{}, false
+}
+
+// peel follows annotated tags to the object they point at
+func (r *Repo) peel(h Hash) (Hash, error) {
+	for i := 0; i < maxSymrefDepth; i++ {
+		typ, data, err := r.readObject(h)
+		if err != nil {
+			return Hash{}, err
+		}
+		if typ != typeTag {
+			return h, nil
+		}
+
+		target, ok := header(data, "object")
+		if !ok {
+			return Hash{}, fmt.Errorf("tag %s has no object", h)
+		}
+		if h, err = ParseHash(target); err != nil {
+			return Hash{}, err
+		}
+	}
+	return Hash{}, fmt.Errorf("tag chain too long")
+}
+
+// parent returns the nth parent of a commit
+func (r *Repo) parent(h Hash, n int, rev string) (Hash, error) {
+	c, err := r.readCommit(h)
+	if err != nil {
+		return Hash{}, err
+	}
+	if n > len(c.parents) {
+		return Hash{}, fmt.Errorf("unknown revision '%s'", rev)
+	}
+	return c.parents[n-1], nil
+}
+
+// readCommit parses the tree, parents and committer time of a commit
+func (r *Repo) readCommit(h Hash) (commit, error) {
+	typ, data, err := r.readObject(h)
+	if err != nil {
+		return commit{}, err
+	}
+	if typ != typeCommit {
+		return commit{}, fmt.Errorf("object %s is not a commit", h)
+	}
+
+	var c commit
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break // the message follows the headers
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			if c.tree, err = ParseHash(value); err != nil {
+				return commit{}, err
+			}
+		case "parent":
+			parent, err := ParseHash(value)
+			if err != nil {
+				return commit{}, err
+			}
+			c.parents = append(c.parents, parent)
+		case "committer":
+			// The time is the second to last field: "Name <email> 1700000000 +0000"
+			fields := strings.Fields(value)
+			if len(fields) >= 2 {
+				if secs, err := strconv.ParseInt(fields[len(fields)-2], 10, 64); err == nil {
+					c.time = time.Unix(secs, 0)
+				}
+			}
+		}
+	}
+	return c, nil
+}
+
+// header returns the value of the first header line of an object
+func header(data []byte, key string) (string, bool) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, key+" "); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// isHex reports whether s only holds hexadecimal digits
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,352 @@
+package gitrev
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go-ls-commands/gitignore"
+)
+
+// statusRank orders status letters by how much they matter when the status
+// of a directory is made up from the files below it
+var statusRank = map[byte]int{'-': 0, '!': 1, '?': 2, 'D': 3, 'A': 4, 'M': 5, 'U': 6}
+
+// Status compares a working tree with its index and HEAD commit, giving a
+// two character code for each path as git status --short does: the first
+// column is the index compared with HEAD and the second the working tree
+// compared with the index. Letters are M for modified, A for added, D for
+// deleted and U for conflicted; ?? marks untracked paths, !! ignored ones
+// and - an unchanged column.
+type Status struct {
+	root      string
+	head      map[string]treeEntry
+	index     map[string]indexEntry
+	conflicts map[string]bool
+	indexTime time.Time
+
+	// tracked holds every path of HEAD and the index in sorted order, so the
+	// files below a directory can be found with a binary search
+	tracked  []string
+	ignores  map[string]*gitignore.Matcher
+	statuses map[string]string
+}
+
+// Status reads the index and HEAD of the repository to report on the
+// working tree at root. A repository without commits has an empty HEAD.
+func (r *Repo) Status(root string) (*Status, error) {
+	s := &Status{
+		root:      root,
+		head:      map[string]treeEntry{},
+		index:     map[string]indexEntry{},
+		conflicts: map[string]bool{},
+		ignores:   map[string]*gitignore.Matcher{},
+		statuses:  map[string]string{},
+	}
+
+	if fsys, err := r.TreeFS("HEAD"); err == nil {
+		if err := s.readHead(fsys, fsys.root, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	idx, err := r.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	s.indexTime = idx.modTime
+	for _, e := range idx.entries {
+		if e.stage != 0 {
+			s.conflicts[e.path] = true
+			continue
+		}
+		s.index[e.path] = e
+	}
+
+	seen := map[string]bool{}
+	for _, paths := range []map[string]bool{s.conflicts, keys(s.head), keys(s.index)} {
+		for path := range paths {
+			if !seen[path] {
+				seen[path] = true
+				s.tracked = append(s.tracked, path)
+			}
+		}
+	}
+	sort.Strings(s.tracked)
+	return s, nil
+}
+
+// keys returns the set of keys of a map
+func keys[V any](m map[string]V) map[string]bool {
+	set := make(map[string]bool, len(m))
+	for k := range m {
+		set[k] = true
+	}
+	return set
+}
+
+// readHead records every file of a tree by its path
+func (s *Status) readHead(fsys *FS, tree Hash, prefix string) error {
+	entries, err := fsys.readTree(tree)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.mode == modeTree {
+			if err := s.readHead(fsys, e.hash, prefix+e.name+"/"); err != nil {
+				return err
+			}
+			continue
+		}
+		s.head[prefix+e.name] = e
+	}
+	return nil
+}
+
+// Of returns the status of a path, which directories make up from the
+// files below them. Paths outside the working tree have no status, and the
+// git directory is shown as unchanged.
+func (s *Status) Of(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(s.root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".git" || strings.HasPrefix(rel, ".git/") {
+		return "--"
+	}
+
+	if status, ok := s.statuses[rel]; ok {
+		return status
+	}
+
+	var status string
+	info, err := os.Lstat(abs)
+	if _, gitlink := s.index[rel]; err == nil && info.IsDir() && !gitlink {
+		status = s.dirStatus(rel)
+	} else {
+		status = s.fileStatus(rel)
+	}
+	s.statuses[rel] = status
+	return status
+}
+
+// fileStatus compares a single file across HEAD, the index and the
+// working tree
+func (s *Status) fileStatus(rel string) string {
+	if s.conflicts[rel] {
+		return "UU"
+	}
+
+	staged, inIndex := s.index[rel]
+	committed, inHead := s.head[rel]
+	if !inIndex {
+		if !inHead {
+			if s.ignored(rel, false) {
+				return "!!"
+			}
+			return "??"
+		}
+
+		// Removed from the index, and untracked if still on disk
+		if _, err := os.Lstat(filepath.Join(s.root, rel)); err == nil {
+			return "D?"
+		}
+		return "D-"
+	}
+
+	x := byte('-')
+	if !inHead {
+		x = 'A'
+	} else if committed.hash != staged.hash || committed.mode != staged.mode {
+		x = 'M'
+	}
+	return string([]byte{x, s.worktreeStatus(rel, staged)})
+}
+
+// worktreeStatus compares the working tree copy of a file with the index,
+// trusting unchanged stat data unless the file changed in the same instant
+// the index was written
+func (s *Status) worktreeStatus(rel string, staged indexEntry) byte {
+	if staged.mode == modeGitlink {
+		return '-' // submodules are not looked into
+	}
+
+	name := filepath.Join(s.root, rel)
+	info, err := os.Lstat(name)
+	if err != nil {
+		return 'D'
+	}
+
+	var mode uint32 = modeBlob
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		mode = modeSymlink
+	case info.IsDir():
+		return 'M'
+	case info.Mode()&0o111 != 0:
+		mode = modeExec
+	}
+	if mode != staged.mode || uint32(info.Size()) != staged.size {
+		return 'M'
+	}
+	if info.ModTime().Equal(staged.mtime) && staged.mtime.Before(s.indexTime) {
+		return '-'
+	}
+
+	var data []byte
+	if mode == modeSymlink {
+		target, err := os.Readlink(name)
+		if err != nil {
+			return 'M'
+		}
+		data = []byte(target)
+	} else if data, err = os.ReadFile(name); err != nil {
+		return 'M'
+	}
+	if hashBlob(data) != staged.hash {
+		return 'M'
+	}
+	return '-'
+}
+
+// dirStatus combines the status of the tracked files below a directory
+// with whether it holds untracked files. A directory with nothing tracked
+// is untracked, or ignored when it holds no untracked file.
+func (s *Status) dirStatus(rel string) string {
+	prefix := rel + "/"
+	if rel == "." {
+		prefix = ""
+	}
+
+	x, y := byte('-'), byte('-')
+	tracked := false
+	for i := sort.SearchStrings(s.tracked, prefix); i < len(s.tracked) && strings.HasPrefix(s.tracked[i], prefix); i++ {
+		status := s.fileStatus(s.tracked[i])
+		tracked = true
+		x, y = maxStatus(x, status[0]), maxStatus(y, status[1])
+	}
+
+	untracked := s.hasUntracked(rel)
+	switch {
+	case !tracked && untracked:
+		return "??"
+	case !tracked && (rel != "." && s.ignored(rel, true) || s.hasIgnored(rel)):
+		return "!!"
+	case untracked:
+		y = maxStatus(y, '?')
+	}
+	return string([]byte{x, y})
+}
+
+// maxStatus returns the status letter that matters most
+func maxStatus(a, b byte) byte {
+	if statusRank[b] > statusRank[a] {
+		return b
+	}
+	return a
+}
+
+// hasUntracked reports whether a directory holds a file that is neither
+// tracked nor ignored. Nested repositories count as untracked files.
+func (s *Status) hasUntracked(rel string) bool {
+	return s.findBelow(rel, func(path string, isDir bool) bool {
+		return !s.ignored(path, isDir)
+	})
+}
+
+// hasIgnored reports whether a directory holds an ignored file
+func (s *Status) hasIgnored(rel string) bool {
+	return s.findBelow(rel, func(path string, isDir bool) bool {
+		return s.ignored(path, isDir)
+	})
+}
+
+// findBelow walks the untracked paths below a directory until match
+// accepts one. Ignored directories are not descended into, but are offered
+// to match themselves.
+func (s *Status) findBelow(rel string, match func(path string, isDir bool) bool) bool {
+	found := false
+	filepath.WalkDir(filepath.Join(s.root, rel), func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		path, _ := filepath.Rel(s.root, name)
+		path = filepath.ToSlash(path)
+		if path == rel {
+			return nil
+		}
+		if path == ".git" {
+			return filepath.SkipDir
+		}
+
+		_, inIndex := s.index[path]
+		switch {
+		case d.IsDir() && inIndex:
+			return filepath.SkipDir // a submodule
+		case d.IsDir() && isRepo(name):
+			found = match(path, false)
+		case d.IsDir() && s.ignored(path, true):
+			found = match(path, true)
+			return filepath.SkipDir
+		case !d.IsDir() && !inIndex && !s.conflicts[path]:
+			found = match(path, false)
+		}
+		if found {
+			return filepath.SkipAll
+		}
+		if d.IsDir() && isRepo(name) {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return found
+}
+
+// isRepo reports whether a directory is the top of another working tree
+func isRepo(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// ignored reports whether a path is ignored by the gitignore files of the
+// working tree
+func (s *Status) ignored(rel string, isDir bool) bool {
+	return s.matcher(filepath.ToSlash(filepath.Dir(rel))).Match(rel, isDir)
+}
+
+// matcher returns the ignore patterns that apply inside a directory
+func (s *Status) matcher(dir string) *gitignore.Matcher {
+	if m, ok := s.ignores[dir]; ok {
+		return m
+	}
+
+	var m *gitignore.Matcher
+	if dir == "." {
+		m = gitignore.NewRepoMatcher(s.root).With(gitignore.ReadFile(filepath.Join(s.root, ".gitignore"), ""))
+	} else {
+		parent := s.matcher(filepath.ToSlash(filepath.Dir(dir)))
+		m = parent.With(gitignore.ReadFile(filepath.Join(s.root, dir, ".gitignore"), dir))
+	}
+	s.ignores[dir] = m
+	return m
+}
+
+// hashBlob returns the name git gives a blob with the given contents
+func hashBlob(data []byte) Hash {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	var sum Hash
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
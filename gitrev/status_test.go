@@ -0,0 +1,198 @@
+package gitrev
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// stagedFile is an index entry to write, whose stat data is taken from the
+// working tree when the file exists there
+type stagedFile struct {
+	path  string
+	mode  uint32
+	hash  Hash
+	stage int
+}
+
+// encodeIndex builds an index file of the given version from entries
+func encodeIndex(t *testing.T, root string, version uint32, files []stagedFile) []byte {
+	t.Helper()
+	sort.SliceStable(files, func(i, j int) bool { return files[i].path < files[j].path })
+
+	var buf bytes.Buffer
+	buf.WriteString("DIRC")
+	_ = binary.Write(&buf, binary.BigEndian, version)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(files)))
+
+	previous := ""
+	for _, f := range files {
+		start := buf.Len()
+		var stat syscall.Stat_t
+		_ = syscall.Lstat(filepath.Join(root, f.path), &stat)
+
+		fields := []uint32{
+			uint32(stat.Ctim.Sec), uint32(stat.Ctim.Nsec), uint32(stat.Mtim.Sec), uint32(stat.Mtim.Nsec),
+			uint32(stat.Dev), uint32(stat.Ino), f.mode, stat.Uid, stat.Gid, uint32(stat.Size),
+		}
+		for _, field := range fields {
+			_ = binary.Write(&buf, binary.BigEndian, field)
+		}
+		buf.Write(f.hash[:])
+		_ = binary.Write(&buf, binary.BigEndian, uint16(f.stage<<12|len(f.path)))
+
+		if version == 4 {
+			common := 0
+			for common < len(previous) && common < len(f.path) && previous[common] == f.path[common] {
+				common++
+			}
+			buf.Write(varintIndex(len(previous) - common))
+			buf.WriteString(f.path[common:])
+			buf.WriteByte(0)
+		} else {
+			buf.WriteString(f.path)
+			buf.Write(make([]byte, 8-(buf.Len()-start)%8))
+		}
+		previous = f.path
+	}
+	return buf.Bytes()
+}
+
+// varintIndex encodes a number the way version 4 indexes do
+func varintIndex(n int) []byte {
+	b := []byte{byte(n & 0x7f)}
+	for n >>= 7; n > 0; n >>= 7 {
+		n--
+		b = append([]byte{byte(0x80 | n&0x7f)}, b...)
+	}
+	return b
+}
+
+// buildWorkTree checks out the fixture repository with a change of every
+// kind, returning the top of the working tree
+func buildWorkTree(t *testing.T, version uint32) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	r := buildRepo(t)
+	root := filepath.Dir(r.gitDir)
+	write := func(name, content string) {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("hello.txt", hello)
+	write("CHANGES", "hello again\n")
+	write("run.sh", "#!/bin/bash\n")
+	write("sub/base.txt", baseText)
+	write("sub/notes.md", "quick brown fox!\n")
+	write("new.txt", "new\n")
+	write("conflict.txt", "<<<<<<<\n")
+	write(".gitignore", "build/\n")
+	write("build/out", "binary")
+	write("untracked/file", "x")
+	_ = os.Chmod(filepath.Join(root, "run.sh"), 0755)
+	_ = os.Mkdir(filepath.Join(root, "module"), 0755)
+	if err := os.Symlink("hello.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stat data is only trusted for files older than the index
+	past := time.Now().Add(-time.Hour)
+	_ = filepath.Walk(root, func(path string, _ os.FileInfo, _ error) error {
+		_ = os.Chtimes(path, past, past)
+		return nil
+	})
+
+	files := []stagedFile{
+		{path: "CHANGES", mode: modeBlob, hash: hashBlob([]byte("hello again\n"))},
+		{path: "hello.txt", mode: modeBlob, hash: hashBlob([]byte(hello))},
+		{path: "link", mode: modeSymlink, hash: hashBlob([]byte("hello.txt"))},
+		{path: "module", mode: modeGitlink, hash: Hash{0xab}},
+		{path: "run.sh", mode: modeExec, hash: hashBlob([]byte("#!/bin/bash\n"))},
+		{path: "sub/base.txt", mode: modeBlob, hash: hashBlob([]byte(baseText))},
+		{path: "sub/notes.md", mode: modeBlob, hash: hashBlob([]byte("quick brown fox!\n"))},
+		{path: "new.txt", mode: modeBlob, hash: hashBlob([]byte("new\n"))},
+		{path: "conflict.txt", mode: modeBlob, hash: Hash{1}, stage: 1},
+		{path: "conflict.txt", mode: modeBlob, hash: Hash{2}, stage: 2},
+		{path: "conflict.txt", mode: modeBlob, hash: Hash{3}, stage: 3},
+	}
+	if err := os.WriteFile(filepath.Join(r.gitDir, "index"), encodeIndex(t, root, version, files), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Change the working tree after the index was written: one file keeps
+	// its size, so only its contents tell it apart once its time differs
+	write("CHANGES", "HELLO AGAIN\n")
+	_ = os.Chtimes(filepath.Join(root, "CHANGES"), past, past.Add(time.Second))
+	_ = os.Chtimes(filepath.Join(root, "hello.txt"), past, past.Add(time.Second))
+	_ = os.Remove(filepath.Join(root, "sub/notes.md"))
+	return root
+}
+
+func TestStatus(t *testing.T) {
+	for _, version := range []uint32{2, 3, 4} {
+		root := buildWorkTree(t, version)
+		repo, err := Open(filepath.Join(root, ".git"))
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		status, err := repo.Status(root)
+		if err != nil {
+			t.Fatalf("Status() index version %d error = %v", version, err)
+		}
+
+		tests := map[string]string{
+			"hello.txt":      "--",
+			"CHANGES":        "-M",
+			"run.sh":         "M-",
+			"new.txt":        "A-",
+			"link":           "--",
+			"module":         "--",
+			"conflict.txt":   "UU",
+			"sub/base.txt":   "--",
+			"sub/notes.md":   "-D",
+			"sub":            "-D",
+			".gitignore":     "??",
+			"untracked":      "??",
+			"untracked/file": "??",
+			"build":          "!!",
+			"build/out":      "!!",
+			".git":           "--",
+			".":              "UU",
+		}
+		for path, want := range tests {
+			if got := status.Of(filepath.Join(root, path)); got != want {
+				t.Errorf("index version %d: Of(%s) = %q, want %q", version, path, got, want)
+			}
+		}
+		if got := status.Of(filepath.Dir(root)); got != "" {
+			t.Errorf("Of() outside the working tree = %q, want none", got)
+		}
+	}
+}
+
+func TestParseIndexFileErrors(t *testing.T) {
+	valid := encodeIndex(t, t.TempDir(), 2, []stagedFile{{path: "a", mode: modeBlob}})
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"signature": append([]byte("DIRX"), valid[4:]...),
+		"version":   append([]byte("DIRC\x00\x00\x00\x05"), valid[8:]...),
+		"truncated": valid[:40],
+	} {
+		if _, err := parseIndexFile(data); err == nil {
+			t.Errorf("parseIndexFile(%s) expected error", name)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Package icons picks nerd font glyphs for files by well-known name,
+// extension and file type. The built-in table can be overridden from a
+// config file.
+package icons
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-ls-commands/colors"
+)
+
+// defaultTypes holds an icon for each file type known to colors.FileType
+var defaultTypes = map[string]string{
+	"di": "\uf07b", // folder
+	"ln": "\uf0c1", // link
+	"ex": "\uf489", // terminal
+	"bd": "\uf0a0", // hard disk
+	"cd": "\uf2db", // microchip
+	"pi": "\uf0ec", // exchange
+	"so": "\uf1e6", // plug
+	"fi": "\uf15b", // file
+}
+
+// defaultNames holds icons for well-known file and directory names
+var defaultNames = map[string]string{
+	".git":           "\ue5fb",
+	".gitattributes": "\ue702",
+	".gitignore":     "\ue702",
+	".gitmodules":    "\ue702",
+	"Cargo.toml":     "\ue7a8",
+	"Dockerfile":     "\uf308",
+	"LICENSE":        "\ue60a",
+	"Makefile":       "\uf489",
+	"README.md":      "\ue609",
+	"go.mod":         "\ue627",
+	"go.sum":         "\ue627",
+	"node_modules":   "\ue5fa",
+	"package.json":   "\ue71e",
+}
+
+// defaultExtensions holds icons for lower case file extensions
+var defaultExtensions = map[string]string{
+	"7z":    "\uf410",
+	"bz2":   "\uf410",
+	"c":     "\ue61e",
+	"cpp":   "\ue61d",
+	"css":   "\ue749",
+	"flac":  "\uf001",
+	"gif":   "\uf1c5",
+	"go":    "\ue627",
+	"gz":    "\uf410",
+	"h":     "\uf0fd",
+	"html":  "\uf13b",
+	"java":  "\ue738",
+	"jpeg":  "\uf1c5",
+	"jpg":   "\uf1c5",
+	"js":    "\ue74e",
+	"json":  "\ue60b",
+	"jsonl": "\ue60b",
+	"lock":  "\uf023",
+	"log":   "\uf18d",
+	"md":    "\ue609",
+	"mkv":   "\uf03d",
+	"mp3":   "\uf001",
+	"mp4":   "\uf03d",
+	"pdf":   "\uf1c1",
+	"png":   "\uf1c5",
+	"py":    "\ue606",
+	"rb":    "\ue791",
+	"rs":    "\ue7a8",
+	"sh":    "\uf489",
+	"svg":   "\uf1c5",
+	"tar":   "\uf410",
+	"toml":  "\ue6b2",
+	"ts":    "\ue628",
+	"txt":   "\uf15c",
+	"wav":   "\uf001",
+	"xz":    "\uf410",
+	"yaml":  "\uf481",
+	"yml":   "\uf481",
+	"zip":   "\uf410",
+}
+
+// Table maps files to icons
+type Table struct {
+	types      map[string]string
+	names      map[string]string
+	extensions map[string]string
+}
+
+// Default returns a table holding the built-in icons
+func Default() *Table {
+	t := &Table{types: map[string]string{}, names: map[string]string{}, extensions: map[string]string{}}
+	for k, v := range defaultTypes {
+		t.types[k] = v
+	}
+	for k, v := range defaultNames {
+		t.names[k] = v
+	}
+	for k, v := range defaultExtensions {
+		t.extensions[k] = v
+	}
+	return t
+}
+
+// Icon returns the icon of a file, chosen by its name first, then by its
+// extension for regular files and executables, and by its type otherwise
+func (t *Table) Icon(file os.FileInfo) string {
+	if icon, ok := t.names[file.Name()]; ok {
+		return icon
+	}
+
+	fileType := colors.FileType(file)
+	if fileType == "fi" || fileType == "ex" {
+		if icon, ok := t.extensions[extension(file.Name())]; ok {
+			return icon
+		}
+	}
+	return t.types[fileType]
+}
+
+// extension returns the lower case extension of a name, without the dot.
+// Names starting with their only dot, like .bashrc, have none.
+func extension(name string) string {
+	i := strings.LastIndexByte(name, '.')
+	if i <= 0 {
+		return ""
+	}
+	return strings.ToLower(name[i+1:])
+}
+
+// Parse reads overrides into the table, one per line in the form
+// "type.di = X", "name.Makefile = X" or "ext.go = X". Empty lines and lines
+// starting with # are skipped, and an empty icon removes an entry.
+func (t *Table) Parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		key, icon, ok := strings.Cut(text, "=")
+		kind, name, dotted := strings.Cut(strings.TrimSpace(key), ".")
+		if !ok || !dotted || name == "" {
+			return fmt.Errorf("line %d: expected kind.name = icon", line)
+		}
+
+		var table map[string]string
+		switch kind {
+		case "type":
+			table = t.types
+		case "name":
+			table = t.names
+		case "ext":
+			table, name = t.extensions, strings.ToLower(name)
+		default:
+			return fmt.Errorf("line %d: unknown kind '%s'", line, kind)
+		}
+
+		if icon = strings.TrimSpace(icon); icon == "" {
+			delete(table, name)
+		} else {
+			table[name] = icon
+		}
+	}
+	return scanner.Err()
+}
+
+// ConfigFile returns where icon overrides are read from:
+// $XDG_CONFIG_HOME/go-ls/icons, or ~/.config/go-ls/icons by default
+func ConfigFile(home, xdgConfigHome string) string {
+	if xdgConfigHome == "" {
+		xdgConfigHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(xdgConfigHome, "go-ls", "icons")
+}
+
+// Load returns the built-in table with the overrides of the user's config
+// file applied, if there is one
+func Load() (*Table, error) {
+	t := Default()
+	home, _ := os.UserHomeDir()
+	name := ConfigFile(home, os.Getenv("XDG_CONFIG_HOME"))
+
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return t, err
+	}
+	defer f.Close()
+
+	if err := t.Parse(f); err != nil {
+		return t, fmt.Errorf("%s: %v", name, err)
+	}
+	return t, nil
+}
+
+// Width returns the number of terminal cells a string takes, counting
+// East Asian wide characters and emoji as two cells and combining marks as
+// none. Nerd font glyphs live in the private use area and take one cell.
+func Width(s string) int {
+	width := 0
+	for _, r := range s {
+		switch {
+		case r >= 0x0300 && r <= 0x036f, r >= 0x200b && r <= 0x200f, r >= 0xfe00 && r <= 0xfe0f:
+			// combining marks, zero width spaces and variation selectors
+		case r >= 0x1100 && r <= 0x115f, r >= 0x2e80 && r <= 0xa4cf, r >= 0xac00 && r <= 0xd7a3,
+			r >= 0xf900 && r <= 0xfaff, r >= 0xfe30 && r <= 0xfe4f, r >= 0xff00 && r <= 0xff60,
+			r >= 0xffe0 && r <= 0xffe6, r >= 0x1f300 && r <= 0x1f64f, r >= 0x1f900 && r <= 0x1f9ff,
+			r >= 0x20000 && r <= 0x3fffd:
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
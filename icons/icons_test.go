@@ -0,0 +1,144 @@
+package icons
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockFileInfo implements os.FileInfo interface for testing
+type mockFileInfo struct {
+	name string
+	mode os.FileMode
+}
+
+func (m mockFileInfo) Name() string       { return m.name }
+func (m mockFileInfo) Size() int64        { return 0 }
+func (m mockFileInfo) Mode() os.FileMode  { return m.mode }
+func (m mockFileInfo) ModTime() time.Time { return time.Time{} }
+func (m mockFileInfo) IsDir() bool        { return m.mode.IsDir() }
+func (m mockFileInfo) Sys() interface{}   { return nil }
+
+func TestIcon(t *testing.T) {
+	table := Default()
+	tests := []struct {
+		name string
+		file mockFileInfo
+		want string
+	}{
+		{"well-known name", mockFileInfo{"Makefile", 0644}, defaultNames["Makefile"]},
+		{"well-known directory", mockFileInfo{".git", os.ModeDir | 0755}, defaultNames[".git"]},
+		{"extension", mockFileInfo{"main.go", 0644}, defaultExtensions["go"]},
+		{"upper case extension", mockFileInfo{"PHOTO.JPG", 0644}, defaultExtensions["jpg"]},
+		{"last extension", mockFileInfo{"src.tar.gz", 0644}, defaultExtensions["gz"]},
+		{"executable script", mockFileInfo{"build.sh", 0755}, defaultExtensions["sh"]},
+		{"executable", mockFileInfo{"tool", 0755}, defaultTypes["ex"]},
+		{"dot file", mockFileInfo{".bashrc", 0644}, defaultTypes["fi"]},
+		{"unknown extension", mockFileInfo{"data.xyz", 0644}, defaultTypes["fi"]},
+		{"directory with extension", mockFileInfo{"site.html", os.ModeDir | 0755}, defaultTypes["di"]},
+		{"symlink", mockFileInfo{"link.go", os.ModeSymlink | 0777}, defaultTypes["ln"]},
+		{"named pipe", mockFileInfo{"fifo", os.ModeNamedPipe | 0644}, defaultTypes["pi"]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := table.Icon(tt.file); got != tt.want {
+				t.Errorf("Icon(%s) = %q, want %q", tt.file.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	table := Default()
+	config := `# overrides
+type.di = D
+name.Makefile=M
+ext.GO = G
+
+ext.md =
+`
+	if err := table.Parse(strings.NewReader(config)); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := map[mockFileInfo]string{
+		{"src", os.ModeDir | 0755}: "D",
+		{"Makefile", 0644}:         "M",
+		{"main.go", 0644}:          "G",
+		{"README.txt", 0644}:       defaultExtensions["txt"],
+		{"notes.md", 0644}:         defaultTypes["fi"],
+	}
+	for file, want := range tests {
+		if got := table.Icon(file); got != want {
+			t.Errorf("Icon(%s) = %q, want %q", file.name, got, want)
+		}
+	}
+
+	// The built-in table is left alone
+	if got := Default().Icon(mockFileInfo{"main.go", 0644}); got != defaultExtensions["go"] {
+		t.Errorf("Default() was changed by Parse()")
+	}
+
+	for _, bad := range []string{"di = x", "type. = x", "color.di = x", "type.di"} {
+		if err := Default().Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("Parse(%q) expected error", bad)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+
+	if _, err := Load(); err != nil {
+		t.Fatalf("Load() without a config file error = %v", err)
+	}
+
+	name := ConfigFile("", config)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte("ext.go = G\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	table, err := Load()
+	if err != nil || table.Icon(mockFileInfo{"main.go", 0644}) != "G" {
+		t.Errorf("Load() did not apply the config file, error = %v", err)
+	}
+
+	if err := os.WriteFile(name, []byte("bogus\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), name) {
+		t.Errorf("Load() error = %v, want one naming the config file", err)
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	if got := ConfigFile("/home/u", ""); got != "/home/u/.config/go-ls/icons" {
+		t.Errorf("ConfigFile() = %s", got)
+	}
+	if got := ConfigFile("/home/u", "/xdg"); got != "/xdg/go-ls/icons" {
+		t.Errorf("ConfigFile() with XDG_CONFIG_HOME = %s", got)
+	}
+}
+
+func TestWidth(t *testing.T) {
+	tests := map[string]int{
+		"":             0,
+		"abc":          3,
+		"\uf07b":       1, // nerd font folder
+		"\U0001F4C1":   2, // emoji folder
+		"\u65e5\u672c": 4, // CJK characters
+		"e\u0301":      1, // e with a combining acute accent
+		"\u2764\ufe0f": 1, // heart with a variation selector
+	}
+	for s, want := range tests {
+		if got := Width(s); got != want {
+			t.Errorf("Width(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
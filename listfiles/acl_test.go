@@ -0,0 +1,69 @@
+package listfiles
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"go-ls-commands/acl"
+)
+
+// setACL stores an ACL on a file, skipping the test where the file system
+// does not allow it
+func setACL(t *testing.T, path, attr string, entries ...acl.Entry) {
+	t.Helper()
+	data := binary.LittleEndian.AppendUint32(nil, 2)
+	for _, e := range entries {
+		data = binary.LittleEndian.AppendUint16(data, uint16(e.Tag))
+		data = binary.LittleEndian.AppendUint16(data, e.Perm)
+		data = binary.LittleEndian.AppendUint32(data, e.ID)
+	}
+	if err := syscall.Setxattr(path, attr, data, 0); err != nil {
+		t.Skipf("Cannot set an ACL: %v", err)
+	}
+}
+
+func TestACL(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"labeled", "shared", "trivial"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const none = 0xffffffff
+	setACL(t, filepath.Join(dir, "shared"), acl.AccessAttr,
+		acl.Entry{Tag: acl.UserObj, Perm: 6, ID: none}, acl.Entry{Tag: acl.User, Perm: 7, ID: 54321},
+		acl.Entry{Tag: acl.GroupObj, Perm: 4, ID: none}, acl.Entry{Tag: acl.Mask, Perm: 6, ID: none},
+		acl.Entry{Tag: acl.Other, Perm: 4, ID: none})
+	setACL(t, filepath.Join(dir, "trivial"), acl.AccessAttr,
+		acl.Entry{Tag: acl.UserObj, Perm: 6, ID: none}, acl.Entry{Tag: acl.GroupObj, Perm: 4, ID: none},
+		acl.Entry{Tag: acl.Other, Perm: 4, ID: none})
+
+	// Attributes other than ACLs earn no +
+	if err := syscall.Setxattr(filepath.Join(dir, "labeled"), "user.comment", []byte("x"), 0); err != nil {
+		t.Skipf("Cannot set an attribute: %v", err)
+	}
+
+	opts := Options{LongFormat: true, ShowACL: true}
+	var out bytes.Buffer
+	renderer := NewRenderer(&out, opts)
+	_ = NewLister(opts).Walk(dir, renderer.RenderDirectory)
+	output := out.String()
+
+	want := "shared\033[0m\n    user::rw-\n    user:54321:rwx\t#effective:rw-\n    group::r--\n    mask::rw-\n    other::r--\n"
+	if !strings.Contains(output, want) {
+		t.Errorf("Output missing %q:\n%s", want, output)
+	}
+
+	// Only the file with an extended ACL is marked and has its ACL listed
+	if strings.Count(output, "+ ") != 1 || strings.Count(output, "user::") != 1 {
+		t.Errorf("Output marks the wrong files:\n%s", output)
+	}
+	if !strings.Contains(output, "\n-rw-r--r--  1 ") {
+		t.Errorf("Output does not align files without an ACL:\n%s", output)
+	}
+}
@@ -0,0 +1,152 @@
+package listfiles
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// separateValueFlags maps options that may take their value as the following
+// argument to the prefix the value is attached to
+var separateValueFlags = map[string]string{
+	"-I":              "-I",
+	"--ignore":        "--ignore=",
+	"--hide":          "--hide=",
+	"--level":         "--level=",
+	"--charset":       "--charset=",
+	"--quoting-style": "--quoting-style=",
+	"--block-size":    "--block-size=",
+	"--files0-from":   "--files0-from=",
+}
+
+// SplitArgs separates command line arguments into options and paths. An
+// argument of -- ends the options, so that names starting with - can be
+// listed, and - alone is always a path.
+func SplitArgs(args []string) (flags, paths []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			paths = append(paths, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			paths = append(paths, arg)
+			continue
+		}
+
+		// Join options that take their value as a separate argument
+		if valueFlag, ok := separateValueFlags[arg]; ok && i+1 < len(args) {
+			i++
+			arg = valueFlag + args[i]
+		}
+		flags = append(flags, arg)
+	}
+	return flags, paths
+}
+
+// ReadFiles0 reads the NUL separated paths given to --files0-from. Empty
+// names are kept in place among the paths, and each is reported in turn by
+// an error of nameErrs giving its position in source, like du does. err is
+// set when the names cannot be read at all.
+func ReadFiles0(r io.Reader, source string) (paths []string, nameErrs []error, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read file names from '%s': %v", source, err)
+	}
+
+	// The last name may or may not be terminated
+	data = bytes.TrimSuffix(data, []byte{0})
+	if len(data) == 0 {
+		return nil, nil, nil
+	}
+
+	paths = strings.Split(string(data), "\x00")
+	for i, name := range paths {
+		if name == "" {
+			nameErrs = append(nameErrs, fmt.Errorf("%s:%d: invalid zero-length file name", source, i+1))
+		}
+	}
+	return paths, nameErrs, nil
+}
+
+// ExpandGlobs replaces each pattern among paths by the files it matches,
+// the way a shell expands unquoted wildcards. As in the shell, wildcards
+// only match names starting with a dot when the pattern does too, and a
+// pattern that matches nothing or is malformed is kept as it is.
+func ExpandGlobs(paths []string) []string {
+	var expanded []string
+	for _, path := range paths {
+		if !hasGlobMeta(path) {
+			expanded = append(expanded, path)
+			continue
+		}
+		if _, err := filepath.Match(path, ""); err != nil {
+			expanded = append(expanded, path)
+			continue
+		}
+		if matches := glob(path); len(matches) > 0 {
+			expanded = append(expanded, matches...)
+		} else {
+			expanded = append(expanded, path)
+		}
+	}
+	return expanded
+}
+
+// hasGlobMeta reports whether a pattern has any characters special to
+// filepath.Match
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// glob matches a pattern one component at a time, keeping the literal
+// components as they were written so that ./*.go gives ./a.go, not a.go
+func glob(pattern string) []string {
+	parts := strings.Split(pattern, "/")
+	matches := []string{""}
+	for i, part := range parts {
+		sep := "/"
+		if i == len(parts)-1 {
+			sep = ""
+		}
+
+		var next []string
+		for _, prefix := range matches {
+			if !hasGlobMeta(part) {
+				next = append(next, prefix+part+sep)
+				continue
+			}
+
+			dir := prefix
+			if dir == "" {
+				dir = "."
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				name := entry.Name()
+				if strings.HasPrefix(name, ".") && !strings.HasPrefix(part, ".") {
+					continue
+				}
+				if ok, _ := filepath.Match(part, name); ok {
+					next = append(next, prefix+name+sep)
+				}
+			}
+		}
+		matches = next
+	}
+
+	// Literal components after the last wildcard may name nothing
+	var found []string
+	for _, match := range matches {
+		if _, err := os.Lstat(match); err == nil {
+			found = append(found, match)
+		}
+	}
+	return found
+}
@@ -0,0 +1,102 @@
+package listfiles_test
+
+import (
+	"go-ls-commands/listfiles"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		args  []string
+		flags []string
+		paths []string
+	}{
+		{[]string{"-l", "dir", "-a"}, []string{"-l", "-a"}, []string{"dir"}},
+		{[]string{"-", "--", "-foo", "--", "-l"}, nil, []string{"-", "-foo", "--", "-l"}},
+		{[]string{"--ignore", "*.o", "--files0-from", "-"}, []string{"--ignore=*.o", "--files0-from=-"}, nil},
+		{[]string{"-I", "--", "x"}, []string{"-I--"}, []string{"x"}},
+		{[]string{"--hide"}, []string{"--hide"}, nil},
+		{nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		flags, paths := listfiles.SplitArgs(tt.args)
+		if !reflect.DeepEqual(flags, tt.flags) || !reflect.DeepEqual(paths, tt.paths) {
+			t.Errorf("SplitArgs(%q) = %q, %q, want %q, %q", tt.args, flags, paths, tt.flags, tt.paths)
+		}
+	}
+}
+
+func TestReadFiles0(t *testing.T) {
+	tests := []struct {
+		input string
+		paths []string
+		errs  []string
+	}{
+		{"", nil, nil},
+		{"a\x00b c\x00", []string{"a", "b c"}, nil},
+		{"a\nb\x00-l", []string{"a\nb", "-l"}, nil},
+		{"\x00a\x00\x00b", []string{"", "a", "", "b"}, []string{"in:1: invalid zero-length file name", "in:3: invalid zero-length file name"}},
+	}
+
+	for _, tt := range tests {
+		paths, errs, err := listfiles.ReadFiles0(strings.NewReader(tt.input), "in")
+		if err != nil {
+			t.Fatalf("ReadFiles0(%q) error = %v", tt.input, err)
+		}
+		var got []string
+		for _, err := range errs {
+			got = append(got, err.Error())
+		}
+		if !reflect.DeepEqual(paths, tt.paths) || !reflect.DeepEqual(got, tt.errs) {
+			t.Errorf("ReadFiles0(%q) = %q, %q, want %q, %q", tt.input, paths, got, tt.paths, tt.errs)
+		}
+	}
+}
+
+func TestExpandGlobs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", ".hidden.go", "notes.txt", "sub/c.go", "sub/deep/d.go"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"*.go"}, []string{"a.go", "b.go"}},
+		{[]string{"./*.go"}, []string{"./a.go", "./b.go"}},
+		{[]string{".*.go"}, []string{".hidden.go"}},
+		{[]string{"*/*.go", "*/*/d.go"}, []string{"sub/c.go", "sub/deep/d.go"}},
+		{[]string{"s*/"}, []string{"sub/"}},
+		{[]string{"*/missing"}, []string{"*/missing"}},
+		{[]string{"[ab].go", "notes.txt"}, []string{"a.go", "b.go", "notes.txt"}},
+		{[]string{`\*.go`, "[a"}, []string{`\*.go`, "[a"}},
+		{[]string{dir + "/n*"}, []string{dir + "/notes.txt"}},
+	}
+
+	for _, tt := range tests {
+		if got := listfiles.ExpandGlobs(tt.patterns); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandGlobs(%q) = %q, want %q", tt.patterns, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package listfiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Severity ranks how much a finding of --audit matters
+type Severity string
+
+// Severities of findings, from the most to the least serious
+const (
+	SeverityHigh   Severity = "high"
+	SeverityMedium Severity = "medium"
+	SeverityLow    Severity = "low"
+)
+
+// rank orders severities for the summary report
+func (s Severity) rank() int {
+	switch s {
+	case SeverityHigh:
+		return 0
+	case SeverityMedium:
+		return 1
+	}
+	return 2
+}
+
+// Finding is a security concern about a file found by --audit
+type Finding struct {
+	Path     string   `json:"path"`
+	Severity Severity `json:"severity"`
+	Reason   string   `json:"reason"`
+}
+
+// audit checks a file for risky permissions, owners that do not exist and
+// symlinks that lead nowhere
+func (l *Lister) audit(path string, info os.FileInfo) []Finding {
+	var findings []Finding
+	add := func(severity Severity, reason string) {
+		findings = append(findings, Finding{Path: path, Severity: severity, Reason: reason})
+	}
+
+	// The mode string holds the type, then rwx for the user, group and
+	// others, with s or S for setuid and setgid and t or T for sticky
+	perm := FileModeToString(info.Mode())
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		// A symlink's own permissions are never used. Its target may be
+		// missing, or unreachable as in a loop of links.
+		if _, err := fs.Stat(l.fsys, path); errors.Is(err, fs.ErrNotExist) {
+			add(SeverityLow, "dangling symlink")
+		} else if err != nil {
+			add(SeverityLow, "broken symlink: "+errorReason(err))
+		}
+	case info.IsDir():
+		if perm[8] == 'w' && perm[9] != 't' && perm[9] != 'T' {
+			add(SeverityHigh, "world-writable directory without sticky bit")
+		}
+	case info.Mode().IsRegular():
+		// Devices, sockets and pipes are meant to be shared, as /dev/null is
+		if perm[8] == 'w' {
+			add(SeverityHigh, "world-writable file")
+		}
+		if perm[3] == 's' || perm[3] == 'S' {
+			add(SeverityHigh, "setuid file")
+		}
+		if perm[6] == 's' || perm[6] == 'S' {
+			add(SeverityMedium, "setgid file")
+		}
+	}
+
+	// Owners and groups without a name are shown by number
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		_, owner, group := statFields(info)
+		if owner == strconv.FormatUint(uint64(stat.Uid), 10) {
+			add(SeverityMedium, "owned by nonexistent user "+owner)
+		}
+		if group == strconv.FormatUint(uint64(stat.Gid), 10) {
+			add(SeverityMedium, "owned by nonexistent group "+group)
+		}
+	}
+
+	return findings
+}
+
+// errorReason returns the cause of a file system error without the
+// operation and path around it
+func errorReason(err error) string {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// auditNote returns the findings of an entry as printed after it with
+// --audit, or keeps them for the summary report printed at the end
+func (r *Renderer) auditNote(entry Entry) string {
+	if r.opts.Audit != "annotate" {
+		r.findings = append(r.findings, entry.Findings...)
+		return ""
+	}
+
+	var note strings.Builder
+	for _, f := range entry.Findings {
+		fmt.Fprintf(&note, " [%s: %s]", f.Severity, f.Reason)
+	}
+	return note.String()
+}
+
+// RenderAudit prints the summary report of --audit=text or --audit=json,
+// with the most serious findings first. The JSON report is the only
+// output of a listing.
+func (r *Renderer) RenderAudit() {
+	if r.opts.Audit != "text" && r.opts.Audit != "json" {
+		return
+	}
+	slices.SortStableFunc(r.findings, func(a, b Finding) int {
+		return a.Severity.rank() - b.Severity.rank()
+	})
+
+	if r.opts.Audit == "json" {
+		findings := r.findings
+		if findings == nil {
+			findings = []Finding{}
+		}
+		data, _ := json.MarshalIndent(findings, "", "  ")
+		fmt.Fprintf(r.report, "%s\n", data)
+		return
+	}
+
+	if r.wrote {
+		fmt.Fprintln(r.w)
+	}
+	fmt.Fprintf(r.w, "audit: %s\n", plural(len(r.findings), "finding", "findings"))
+	for _, f := range r.findings {
+		fmt.Fprintf(r.w, "%-6s %s: %s\n", f.Severity, r.quote(f.Path), f.Reason)
+	}
+}
@@ -0,0 +1,131 @@
+package listfiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAudit(t *testing.T) {
+	fsys := fstest.MapFS{
+		"fine":       {Mode: 0644},
+		"shared":     {Mode: 0666},
+		"setuid":     {Mode: 0755 | os.ModeSetuid},
+		"setgid":     {Mode: 0755 | os.ModeSetgid},
+		"open":       {Mode: 0777 | os.ModeDir},
+		"tmp":        {Mode: 0777 | os.ModeDir | os.ModeSticky},
+		"group-dir":  {Mode: 0755 | os.ModeDir | os.ModeSetgid},
+		"link":       {Mode: 0777 | os.ModeSymlink, Data: []byte("fine")},
+		"dangling":   {Mode: 0777 | os.ModeSymlink, Data: []byte("missing")},
+		"everything": {Mode: 0777 | os.ModeSetuid | os.ModeSetgid},
+		"null":       {Mode: 0666 | os.ModeDevice | os.ModeCharDevice},
+		"fifo":       {Mode: 0666 | os.ModeNamedPipe},
+	}
+	lister := NewFSLister(fsys, Options{Audit: "annotate"})
+
+	tests := map[string]string{
+		"fine":       "",
+		"shared":     "high: world-writable file",
+		"setuid":     "high: setuid file",
+		"setgid":     "medium: setgid file",
+		"open":       "high: world-writable directory without sticky bit",
+		"tmp":        "",
+		"group-dir":  "",
+		"link":       "",
+		"dangling":   "low: dangling symlink",
+		"everything": "high: world-writable file, high: setuid file, medium: setgid file",
+		"null":       "",
+		"fifo":       "",
+	}
+	for name, want := range tests {
+		info, err := lister.fsys.Lstat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range lister.audit(name, info) {
+			got = append(got, string(f.Severity)+": "+f.Reason)
+		}
+		if strings.Join(got, ", ") != want {
+			t.Errorf("audit(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestAuditSymlinkLoop(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Symlink("loop", filepath.Join(dir, "loop")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "loop")
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := NewLister(Options{Audit: "annotate"}).audit(path, info)
+	want := Finding{Path: path, Severity: SeverityLow, Reason: "broken symlink: too many levels of symbolic links"}
+	if len(findings) != 1 || findings[0] != want {
+		t.Errorf("audit(loop) = %+v, want %+v", findings, want)
+	}
+}
+
+func TestAuditOwners(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "orphan")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Lchown(path, 54321, 54321); err != nil {
+		t.Skipf("Cannot change the owner: %v", err)
+	}
+
+	info, _ := os.Lstat(path)
+	findings := NewLister(Options{}).audit(path, info)
+	if len(findings) != 2 || findings[0].Reason != "owned by nonexistent user 54321" ||
+		findings[1].Reason != "owned by nonexistent group 54321" {
+		t.Errorf("audit() = %+v", findings)
+	}
+}
+
+func TestAuditReport(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dangling": {Mode: 0777 | os.ModeSymlink, Data: []byte("missing")},
+		"fine":     {Mode: 0644},
+		"shared":   {Mode: 0666},
+	}
+
+	render := func(audit string) string {
+		opts := Options{OnePerLine: true, Audit: audit}
+		var out bytes.Buffer
+		renderer := NewRenderer(&out, opts)
+		_ = NewFSLister(fsys, opts).Walk(".", renderer.RenderDirectory)
+		renderer.RenderAudit()
+		return out.String()
+	}
+
+	if got := render("annotate"); !strings.Contains(got, "shared\033[0m [high: world-writable file]\n") ||
+		!strings.Contains(got, "fine\033[0m\n") {
+		t.Errorf("Annotated output:\n%q", got)
+	}
+
+	// The report lists the most serious findings first
+	want := "\naudit: 2 findings\nhigh   ./shared: world-writable file\nlow    ./dangling: dangling symlink\n"
+	if got := render("text"); !strings.HasSuffix(got, want) || strings.Contains(got, "[high") {
+		t.Errorf("Text report:\n%q\nwant suffix %q", got, want)
+	}
+
+	// The JSON report is the whole output
+	got := render("json")
+	var findings []Finding
+	if err := json.Unmarshal([]byte(got), &findings); err != nil {
+		t.Fatalf("JSON report does not parse: %v\n%s", err, got)
+	}
+	if len(findings) != 2 || findings[0] != (Finding{Path: "./shared", Severity: SeverityHigh, Reason: "world-writable file"}) {
+		t.Errorf("JSON report = %+v", findings)
+	}
+}
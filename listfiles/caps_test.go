@@ -0,0 +1,55 @@
+package listfiles
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"go-ls-commands/capability"
+)
+
+func TestCapsAndAttrs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"plain", "server"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// cap_net_bind_service+ep in a revision 2 attribute
+	var value []byte
+	for _, word := range []uint32{0x02000001, 1 << 10, 0, 0, 0} {
+		value = binary.LittleEndian.AppendUint32(value, word)
+	}
+	if err := syscall.Setxattr(filepath.Join(dir, "server"), capability.Attr, value, 0); err != nil {
+		t.Skipf("Cannot set capabilities: %v", err)
+	}
+
+	opts := Options{LongFormat: true, Caps: true, Attrs: true}
+	var out bytes.Buffer
+	renderer := NewRenderer(&out, opts)
+	_ = NewLister(opts).Walk(dir, renderer.RenderDirectory)
+
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("Output has too few lines:\n%s", out.String())
+	}
+	plain, server := strings.Fields(lines[1]), strings.Fields(lines[2])
+
+	// Either the flags are readable, or every file shows ?
+	if plain[4] != server[4] || (plain[4] != "?" && len(plain[4]) != 22) {
+		t.Errorf("Inode flags columns = %q, %q", plain[4], server[4])
+	}
+	if plain[5] != "-" || server[5] != "cap_net_bind_service+ep" {
+		t.Errorf("Capability columns = %q, %q", plain[5], server[5])
+	}
+
+	// The columns are padded so the sizes line up
+	if strings.Index(lines[1], " 0 ") != strings.Index(lines[2], " 0 ") {
+		t.Errorf("Columns are not aligned:\n%s", out.String())
+	}
+}
@@ -0,0 +1,92 @@
+package listfiles
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestSecurityContext(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"labeled", "plain"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	label := "system_u:object_r:tmp_t:s0"
+	if err := syscall.Setxattr(filepath.Join(dir, "labeled"), selinuxAttr, []byte(label+"\x00"), 0); err != nil {
+		t.Skipf("Cannot set a security context: %v", err)
+	}
+
+	render := func(opts Options) string {
+		var out bytes.Buffer
+		renderer := NewRenderer(&out, opts)
+		_ = NewLister(opts).Walk(dir, renderer.RenderDirectory)
+		return out.String()
+	}
+
+	// The context is a column after the group, and a labeled file gets a .
+	long := render(Options{LongFormat: true, Context: true})
+	for _, want := range []string{
+		"-rw-r--r--. 1 ",
+		" " + label + " 0 ",
+		"-rw-r--r--  1 ",
+		" ?" + strings.Repeat(" ", len(label)) + "0 ",
+	} {
+		if !strings.Contains(long, want) {
+			t.Errorf("Long output missing %q:\n%s", want, long)
+		}
+	}
+
+	// The . shows without -Z too, with no column
+	if plain := render(Options{LongFormat: true}); !strings.Contains(plain, "-rw-r--r--. 1 ") || strings.Contains(plain, label) {
+		t.Errorf("Long output without -Z:\n%s", plain)
+	}
+
+	// Short format right aligns the contexts before the names
+	short := render(Options{OnePerLine: true, Context: true})
+	padding := strings.Repeat(" ", len(label)-1)
+	if !strings.Contains(short, label+" \033[0mlabeled") || !strings.Contains(short, padding+"? \033[0mplain") {
+		t.Errorf("Short output:\n%q", short)
+	}
+}
+
+func TestSecurityLabelsReadOnce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries carry the labels read when they were created
+	entry := NewLister(Options{LongFormat: true, Context: true}).newEntry(path, info)
+	if _, ok := entry.Info.(labeledFileInfo); !ok {
+		t.Fatalf("newEntry() info is %T, want labeledFileInfo", entry.Info)
+	}
+	if plain := NewLister(Options{}).newEntry(path, info); plain.Info != info {
+		t.Errorf("newEntry() without long format or -Z wrapped the info in %T", plain.Info)
+	}
+
+	// Measuring and printing use the carried labels, not the file's
+	labeled := labeledFileInfo{info, securityLabels{context: "user_u:object_r:fake_t:s0"}}
+	maxLengths := map[string]int{"context": 0}
+	updateFieldLengths(dir, labeled, Options{}, maxLengths)
+	if maxLengths["context"] != len("user_u:object_r:fake_t:s0") || maxLengths["permissions"] != len("-rw-r--r--.") {
+		t.Errorf("updateFieldLengths() = %v", maxLengths)
+	}
+	if got := longFields(dir, labeled, Options{}, maxLengths); !strings.HasPrefix(got, "-rw-r--r--. ") || !strings.Contains(got, " user_u:object_r:fake_t:s0 ") {
+		t.Errorf("longFields() = %q", got)
+	}
+
+	labeled.labels = securityLabels{acl: true}
+	if got := longFields(dir, labeled, Options{}, maxLengths); !strings.HasPrefix(got, "-rw-r--r--+ ") {
+		t.Errorf("longFields() with an ACL = %q", got)
+	}
+}
@@ -1,8 +1,10 @@
 package listfiles_test
 
 import (
+	"bytes"
 	"go-ls-commands/listfiles"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -32,41 +34,212 @@ func createTempDir(t *testing.T) string {
 	return tmpDir
 }
 
+// entryNames returns the names of the entries of a directory listing
+func entryNames(dir listfiles.Directory) []string {
+	names := make([]string, 0, len(dir.Entries))
+	for _, entry := range dir.Entries {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
 func TestListFiles(t *testing.T) {
 	tmpDir := createTempDir(t)
-	opts := listfiles.Options{
-		Recursive:   false,
-		AllFiles:    false,
-		LongFormat:  false,
-		SortByTime:  false,
-		ReverseSort: false,
+
+	tests := []struct {
+		name     string
+		opts     listfiles.Options
+		expected [][]string
+	}{
+		{
+			name:     "Normal file listing",
+			opts:     listfiles.Options{},
+			expected: [][]string{{"file1.txt", "file2.txt", "newer.txt", "subdir"}},
+		},
+		{
+			name: "Recursive listing",
+			opts: listfiles.Options{Recursive: true},
+			expected: [][]string{
+				{"file1.txt", "file2.txt", "newer.txt", "subdir"},
+				{"file3.txt"},
+			},
+		},
+		{
+			name:     "Listing with hidden files",
+			opts:     listfiles.Options{AllFiles: true},
+			expected: [][]string{{".", "..", "file1.txt", "file2.txt", ".hidden.txt", "newer.txt", "subdir"}},
+		},
+		{
+			name:     "Sorting by time",
+			opts:     listfiles.Options{SortByTime: true},
+			expected: [][]string{{"newer.txt"}},
+		},
+		{
+			name:     "Reverse sorting",
+			opts:     listfiles.Options{ReverseSort: true},
+			expected: [][]string{{"subdir", "newer.txt", "file2.txt", "file1.txt"}},
+		},
 	}
 
-	t.Run("Normal file listing", func(t *testing.T) {
-		listfiles.ListFiles(tmpDir, opts, true)
-	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dirs := listfiles.NewLister(tt.opts).List(tmpDir)
+			if len(dirs) != len(tt.expected) {
+				t.Fatalf("List() returned %d directories, want %d", len(dirs), len(tt.expected))
+			}
 
-	t.Run("Recursive listing", func(t *testing.T) {
-		opts.Recursive = true
-		listfiles.ListFiles(tmpDir, opts, true)
-	})
+			for i, dir := range dirs {
+				if dir.Err != nil {
+					t.Fatalf("List() directory %s error = %v", dir.Path, dir.Err)
+				}
+				names := entryNames(dir)
 
-	t.Run("Listing with hidden files", func(t *testing.T) {
-		opts.Recursive = false
-		opts.AllFiles = true
-		listfiles.ListFiles(tmpDir, opts, true)
-	})
+				// Time sorting only fixes the position of the newest file
+				if tt.opts.SortByTime {
+					if names[0] != tt.expected[i][0] {
+						t.Errorf("List() first entry = %s, want %s", names[0], tt.expected[i][0])
+					}
+					continue
+				}
+				if strings.Join(names, " ") != strings.Join(tt.expected[i], " ") {
+					t.Errorf("List() directory %s = %v, want %v", dir.Path, names, tt.expected[i])
+				}
+			}
+		})
+	}
+}
 
-	t.Run("Sorting by time", func(t *testing.T) {
-		opts.SortByTime = true
-		listfiles.ListFiles(tmpDir, opts, true)
-	})
+func TestLister(t *testing.T) {
+	tmpDir := createTempDir(t)
+	_ = os.Symlink(tmpDir+"/file1.txt", tmpDir+"/link")
+
+	dirs := listfiles.NewLister(listfiles.Options{}).List(tmpDir)
+	for _, entry := range dirs[0].Entries {
+		if entry.Path != tmpDir+"/"+entry.Name {
+			t.Errorf("Entry %s has path %s", entry.Name, entry.Path)
+		}
+		if entry.Name == "link" && entry.LinkTarget != tmpDir+"/file1.txt" {
+			t.Errorf("Entry link has target %q", entry.LinkTarget)
+		}
+	}
 
-	t.Run("Reverse sorting", func(t *testing.T) {
-		opts.SortByTime = false
-		opts.ReverseSort = true
-		listfiles.ListFiles(tmpDir, opts, true)
+	// Walk stops at the first error returned by the callback
+	stop := os.ErrClosed
+	calls := 0
+	err := listfiles.NewLister(listfiles.Options{Recursive: true}).Walk(tmpDir, func(listfiles.Directory) error {
+		calls++
+		return stop
 	})
+	if err != stop || calls != 1 {
+		t.Errorf("Walk() = %v after %d calls, want %v after 1 call", err, calls, stop)
+	}
+
+	// Missing directories are reported on the listing
+	dirs = listfiles.NewLister(listfiles.Options{}).List(tmpDir + "/missing")
+	if len(dirs) != 1 || dirs[0].Err == nil {
+		t.Errorf("List() of a missing directory = %+v, want an error", dirs)
+	}
+}
+
+func TestListerArgs(t *testing.T) {
+	tmpDir := createTempDir(t)
+
+	lister := listfiles.NewLister(listfiles.Options{})
+	files, dirs, errs := lister.Args([]string{tmpDir + "/file1.txt", tmpDir + "/subdir", tmpDir + "/missing"})
+	if len(files) != 1 || files[0].Name != tmpDir+"/file1.txt" {
+		t.Errorf("Args() files = %+v", files)
+	}
+	if len(dirs) != 1 || dirs[0] != tmpDir+"/subdir" {
+		t.Errorf("Args() dirs = %v", dirs)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "No such file or directory") {
+		t.Errorf("Args() errs = %v", errs)
+	}
+
+	// With -d directories are listed like files
+	lister = listfiles.NewLister(listfiles.Options{DirectoryOnly: true})
+	files, dirs, _ = lister.Args([]string{tmpDir + "/subdir"})
+	if len(files) != 1 || len(dirs) != 0 {
+		t.Errorf("Args() with -d = %+v, %v", files, dirs)
+	}
+}
+
+func TestRenderer(t *testing.T) {
+	tmpDir := createTempDir(t)
+
+	tests := []struct {
+		name     string
+		opts     listfiles.Options
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "Short format",
+			opts:     listfiles.Options{},
+			contains: []string{"file1.txt\033[0m ", "subdir\033[0m \n"},
+			excludes: []string{"total", ".hidden.txt", ":\n"},
+		},
+		{
+			name:     "One per line",
+			opts:     listfiles.Options{OnePerLine: true},
+			contains: []string{"file1.txt\033[0m\n", "newer.txt\033[0m\n"},
+		},
+		{
+			name:     "Long format",
+			opts:     listfiles.Options{LongFormat: true},
+			contains: []string{"total ", "-rw-r--r-- 1 ", " 14 ", "drwxr-xr-x 2 "},
+		},
+		{
+			name:     "Recursive headers",
+			opts:     listfiles.Options{Recursive: true},
+			contains: []string{tmpDir + ":\n", "\n\n" + tmpDir + "/subdir:\n", "file3.txt"},
+		},
+		{
+			name:     "Unsorted long format",
+			opts:     listfiles.Options{Unsorted: true, LongFormat: true},
+			contains: []string{"total ", "file2.txt"},
+			excludes: []string{"\ntotal ", "total 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			renderer := listfiles.NewRenderer(&buf, tt.opts)
+			if err := listfiles.NewLister(tt.opts).Walk(tmpDir, renderer.RenderDirectory); err != nil {
+				t.Fatalf("Walk() error = %v", err)
+			}
+
+			output := buf.String()
+			for _, want := range tt.contains {
+				if !strings.Contains(output, want) {
+					t.Errorf("Output missing %q:\n%s", want, output)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(output, unwanted) {
+					t.Errorf("Output contains %q:\n%s", unwanted, output)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderFiles(t *testing.T) {
+	tmpDir := createTempDir(t)
+
+	var buf bytes.Buffer
+	opts := listfiles.Options{LongFormat: true}
+	files, _, _ := listfiles.NewLister(opts).Args([]string{tmpDir + "/file1.txt", tmpDir + "/newer.txt"})
+	listfiles.NewRenderer(&buf, opts).RenderFiles(files)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("RenderFiles() wrote %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], tmpDir+"/file1.txt") || strings.Contains(buf.String(), "total") {
+		t.Errorf("RenderFiles() output = %q", buf.String())
+	}
 }
 
 func TestCalculateFileMetadata(t *testing.T) {
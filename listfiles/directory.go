@@ -1,143 +1,384 @@
 package listfiles
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
 
-	filepaths "go-ls-commands/filepath"
+	"go-ls-commands/dirsize"
+	"go-ls-commands/gitrev"
+	"go-ls-commands/predicate"
 	"go-ls-commands/sorting"
 )
 
-// ListFiles handles the listing of files and directories with various formats
-func ListFiles(path string, opts Options, isFirst bool) {
-	// Only show the directory header if recursive flag is set
-	if isFirst && opts.Recursive {
-		fmt.Println(path + ":")
+// Entry is a single file in a listing
+type Entry struct {
+	Name       string
+	Path       string
+	Info       os.FileInfo
+	LinkTarget string
+
+	// GitStatus is the two character status of the file for --git
+	GitStatus string
+
+	// AbsPath is the absolute path of a file of the operating system, and
+	// is empty for files of other file systems
+	AbsPath string
+
+	// Findings holds the concerns about the file found by --audit
+	Findings []Finding
+}
+
+// Directory is the listing of a single directory. When listing unsorted, a
+// directory is delivered in several batches: every batch but the first is
+// marked Continued, and every batch but the last is marked Partial.
+type Directory struct {
+	Path      string
+	Entries   []Entry
+	Err       error
+	Continued bool
+	Partial   bool
+}
+
+// Lister collects directory listings according to the options
+type Lister struct {
+	opts Options
+	fsys listFS
+	ctx  context.Context
+
+	// sizes adds up directory sizes for --dir-size and --total, with one
+	// bound on its goroutines for the whole listing
+	sizes *dirsize.Counter
+
+	// Working tree statuses for --git, by repository root, and the root
+	// found for each directory
+	gitStatuses map[string]*gitrev.Status
+	gitRoots    map[string]string
+
+	// gitIgnores holds the gitignore patterns of each directory visited
+	// with --gitignore
+	gitIgnores *gitIgnoreCache
+}
+
+// NewLister creates a lister for the operating system's files
+func NewLister(opts Options) *Lister {
+	return newLister(osFS{}, opts)
+}
+
+// NewFSLister creates a lister for any io/fs.FS, such as an embed.FS, a
+// zip.Reader or an fstest.MapFS. Symlinks are only recognised when fsys
+// implements LstatFS and ReadLinkFS, and long format fields the file system
+// cannot supply are shown as ?.
+func NewFSLister(fsys fs.FS, opts Options) *Lister {
+	return newLister(cleanFS{fsys: fsys}, opts)
+}
+
+// NewFSListerAt creates a lister for an io/fs.FS mounted at root, so that
+// the paths it is given and lists start with root, as when browsing the
+// inside of an archive through its path
+func NewFSListerAt(fsys fs.FS, root string, opts Options) *Lister {
+	return newLister(cleanFS{fsys: fsys, root: root}, opts)
+}
+
+// NewFSListerIn creates a lister for an io/fs.FS mounted at root whose
+// relative paths start from dir, as when listing a git revision from a
+// subdirectory of its working tree. Absolute paths under root are also
+// accepted.
+func NewFSListerIn(fsys fs.FS, root, dir string, opts Options) *Lister {
+	return newLister(cleanFS{fsys: fsys, root: root, dir: dir}, opts)
+}
+
+// newLister creates a lister reading from fsys
+func newLister(fsys listFS, opts Options) *Lister {
+	return &Lister{opts: opts, fsys: fsys, sizes: dirsize.New(fsys, opts.AllocatedSize), gitIgnores: &gitIgnoreCache{}}
+}
+
+// WithContext returns a copy of the lister that stops walking, and adding
+// up directory sizes, once ctx is cancelled
+func (l *Lister) WithContext(ctx context.Context) *Lister {
+	clone := *l
+	clone.ctx = ctx
+	return &clone
+}
+
+// context returns the context of the lister
+func (l *Lister) context() context.Context {
+	if l.ctx == nil {
+		return context.Background()
+	}
+	return l.ctx
+}
+
+// List returns the listing of a directory, followed by those of its
+// subdirectories when listing recursively
+func (l *Lister) List(path string) []Directory {
+	var dirs []Directory
+	l.Walk(path, func(dir Directory) error {
+		dirs = append(dirs, dir)
+		return nil
+	})
+	return dirs
+}
+
+// Walk calls fn with the listing of a directory, and then with those of its
+// subdirectories when listing recursively. It stops at the first error
+// returned by fn.
+func (l *Lister) Walk(path string, fn func(Directory) error) error {
+	if err := l.context().Err(); err != nil {
+		return err
 	}
 
-	// List current directory contents
-	serveDir(path, opts)
+	var subdirs []string
+	var err error
+
+	// Stream the directory when unsorted, so huge directories are never
+	// loaded into memory as a whole
+	if l.opts.Unsorted {
+		subdirs, err = l.streamDir(path, fn)
+	} else {
+		var dir Directory
+		dir, subdirs = l.serveDir(path)
+		err = fn(dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Handle recursive directory traversal
+	if l.opts.Recursive {
+		return l.processRecursive(path, subdirs, fn)
+	}
+	return nil
+}
+
+// Args sorts the paths named on the command line into files, which are
+// listed by the name they were given under, and directories to walk.
+// Paths that cannot be accessed are reported as errors.
+func (l *Lister) Args(paths []string) ([]Entry, []string, []error) {
+	var files []Entry
+	var dirs []string
+	var errs []error
+
+	for _, path := range paths {
+		// Check if path exists
+		fileInfo, err := l.lstat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("cannot access '%s': No such file or directory", path))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot access '%s': %v", path, err))
+			continue
+		}
 
-	if opts.Recursive && len(path) > 1 {
-		if strings.HasSuffix(path, "/") {
-			path = strings.Trim(path, "/")
+		// Follow symlinks to directories unless listing in long format
+		if fileInfo.Mode()&os.ModeSymlink != 0 && !l.opts.LongFormat && !l.opts.DirectoryOnly {
+			target, err := l.readLink(path)
+			if err == nil {
+				targetFileInfo, err := l.lstat(linkTargetPath(path, target))
+				if err == nil && targetFileInfo.IsDir() {
+					fileInfo = targetFileInfo
+				}
+			}
+		}
+
+		// With -d directories are listed like files, and never recursed into
+		if fileInfo.IsDir() && !l.opts.DirectoryOnly {
+			dirs = append(dirs, path)
+		} else {
+			info := l.withDirSize(path, CustomFileInfo{fileInfo, path})
+			files = append(files, l.newEntry(path, info))
 		}
 	}
 
-	// Normalize path for recursive operations
-	if opts.Recursive && len(path) > 1 {
-		// if strings.HasSuffix(path, "/") {
-		// 	path = strings.TrimSuffix(path, "/")
-		// }
-		path = strings.TrimSuffix(path, "/")
+	return files, dirs, errs
+}
+
+// newEntry creates the entry for a file found at path
+func (l *Lister) newEntry(path string, info os.FileInfo) Entry {
+	entry := Entry{Name: info.Name(), Path: path, Info: info, GitStatus: l.gitStatus(path)}
+	if _, ok := l.fsys.(osFS); ok {
+		entry.AbsPath, _ = filepath.Abs(path)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		entry.LinkTarget, _ = l.readLink(path)
+	}
+	if l.opts.Audit != "" {
+		entry.Findings = l.audit(path, info)
 	}
 
-	// Handle recursive directory traversal
-	if opts.Recursive {
-		processRecursive(path, opts)
+	// Security labels are read once for both measuring and printing
+	if _, ok := info.Sys().(*syscall.Stat_t); ok && (l.opts.LongFormat || l.opts.Context) {
+		entry.Info = labeledFileInfo{info, readLabels(path, info, l.opts.Context)}
+	}
+	return entry
+}
+
+// readDir reads up to n entries of an open directory, or all of them when
+// n is zero or less. Entries that vanish before they can be described are
+// left out.
+func readDir(f fs.File, n int) ([]os.FileInfo, error) {
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Err: errors.New("not a directory")}
 	}
+
+	entries, err := dir.ReadDir(n)
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos, err
 }
 
-// serveDir handles listing files in a directory
-func serveDir(dir string, opts Options) {
-	f, err := os.Open(dir)
+// serveDir reads, filters and sorts the files in a directory, and returns
+// them along with the subdirectories to recurse into
+func (l *Lister) serveDir(dir string) (Directory, []string) {
+	f, err := l.fsys.Open(dir)
 	if err != nil {
-		fmt.Println("Error: ", err)
-		return
+		return Directory{Path: dir, Err: err}, nil
 	}
 	defer f.Close()
 
 	// Read all files in the directory
-	files, err := f.Readdir(0)
+	files, err := readDir(f, 0)
 	if err != nil {
-		fmt.Println("Error: ", err)
-		return
+		return Directory{Path: dir, Err: err}, nil
 	}
 
 	var fileInfos []os.FileInfo
 
 	// Add . and .. if allFiles is set
-	if opts.AllFiles {
-		curDirInfo, _ := os.Stat(dir)
-		fileInfos = append(fileInfos, CustomFileInfo{curDirInfo, "."})
-
-		parentDir := filepaths.GetParentDir(dir)
-		parentDirInfo, _ := os.Stat(parentDir)
-		fileInfos = append(fileInfos, CustomFileInfo{parentDirInfo, ".."})
+	if l.opts.AllFiles {
+		fileInfos = append(fileInfos, l.dotEntries(dir)...)
 	}
 
 	// Filter and add other files
 	for _, file := range files {
-		if !opts.AllFiles && strings.HasPrefix(file.Name(), ".") {
-			continue // skip hidden files if -a is not set
+		if l.isExcluded(dir, file) {
+			continue // skip hidden and ignored files
 		}
 		fileInfos = append(fileInfos, file)
 	}
+	l.applyDirSizes(dir, fileInfos)
 
-	// Sort files based on options
-	sortFiles(fileInfos, opts)
-
-	// Print files with correct format
-	printDirectory(dir, fileInfos, opts)
-}
-
-// processRecursive handles recursive directory traversal
-func processRecursive(path string, opts Options) {
-	files, err := os.ReadDir(path)
-	if err != nil {
-		fmt.Printf("cannot read directory '%s': %v\n", path, err)
-		return
+	// Find subdirectories for recursion, whether or not they are listed
+	var subdirInfos []os.FileInfo
+	recurse := map[string]bool{}
+	for _, file := range fileInfos {
+		isDot := file.Name() == "." || file.Name() == ".."
+		if !isDot && l.isRecursionCandidate(dir, file) {
+			subdirInfos = append(subdirInfos, file)
+			recurse[file.Name()] = true
+		}
 	}
 
-	fileInfos := make([]fs.FileInfo, 0)
-	for _, v := range files {
-		fileInfo, _ := v.Info()
-		fileInfos = append(fileInfos, fileInfo)
+	// Skip files not selected by the find-style filters. The tree view
+	// keeps subdirectories so the files below them stay in place. Symlinks
+	// to directories are never descended into, so they are filtered by
+	// their own type like other files.
+	if len(l.opts.Predicates) > 0 {
+		fileInfos = slices.DeleteFunc(fileInfos, func(file os.FileInfo) bool {
+			if l.opts.Tree && file.IsDir() && recurse[file.Name()] {
+				return false
+			}
+			return !predicate.MatchAll(l.opts.Predicates, l.fsys, joinPath(dir, file.Name()), file)
+		})
 	}
 
-	// Sort files based on options
-	sortFiles(fileInfos, opts)
+	// Sort files based on options, and subdirectories in the same order
+	sortFiles(fileInfos, l.opts)
+	sortFiles(subdirInfos, l.opts)
 
-	// Find subdirectories for recursion
-	var dirs []string
+	listing := Directory{Path: dir}
 	for _, file := range fileInfos {
-		// Check if the file is a symlink
-		if file.Mode()&os.ModeSymlink != 0 {
-			// Resolve the symlink
-			target, err := os.Readlink(path + "/" + file.Name())
-			if err != nil {
-				fmt.Printf("Error resolving symlink: %v\n", err)
-				continue
-			}
-			// Check if the target is a directory
-			targetInfo, err := os.Lstat(target)
-			if err == nil && targetInfo.IsDir() {
-				// Add the symlinked directory to the list of directories
-				dirs = append(dirs, file.Name())
-			}
-		} else if file.IsDir() && (opts.AllFiles || !strings.HasPrefix(file.Name(), ".")) {
-			dirs = append(dirs, file.Name())
+		listing.Entries = append(listing.Entries, l.newEntry(joinPath(dir, file.Name()), file))
+	}
+
+	var subdirs []string
+	for _, file := range subdirInfos {
+		subdirs = append(subdirs, file.Name())
+	}
+	return listing, subdirs
+}
+
+// dotEntries describes the . and .. entries of a directory. They are found
+// through dir/. and dir/.. like the kernel resolves them, so .. is the real
+// parent of a directory reached through a symlink or of the working
+// directory. An entry that cannot be described is left out.
+func (l *Lister) dotEntries(dir string) []os.FileInfo {
+	var infos []os.FileInfo
+	for _, name := range []string{".", ".."} {
+		if info, err := l.stat(joinPath(dir, name)); err == nil {
+			infos = append(infos, CustomFileInfo{info, name})
 		}
 	}
+	return infos
+}
 
-	// Process each subdirectory recursively
+// processRecursive walks each of the named subdirectories of path
+func (l *Lister) processRecursive(path string, dirs []string, fn func(Directory) error) error {
 	for _, dirName := range dirs {
-		fullPath := path + "/" + dirName
-		fmt.Printf("\n%s:\n", fullPath)
-		ListFiles(fullPath, opts, false)
+		if err := l.Walk(joinPath(path, dirName), fn); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// joinPath joins a directory and a name without doubling the separator
+func joinPath(dir, name string) string {
+	if strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+// isRecursionCandidate reports whether a directory entry should be descended into
+func (l *Lister) isRecursionCandidate(path string, file os.FileInfo) bool {
+	// Ignored directories are never descended into
+	if l.isExcluded(path, file) {
+		return false
+	}
+
+	// Check if the file is a symlink
+	if file.Mode()&os.ModeSymlink != 0 {
+		// Resolve the symlink
+		link := joinPath(path, file.Name())
+		target, err := l.readLink(link)
+		if err != nil {
+			return false
+		}
+		// Check if the target is a directory
+		targetInfo, err := l.lstat(linkTargetPath(link, target))
+		return err == nil && targetInfo.IsDir()
+	}
+
+	return file.IsDir()
 }
 
 // sortFiles applies sorting based on the provided options
 func sortFiles(fileInfos []os.FileInfo, opts Options) {
+	// Keep directory order when unsorted
+	if opts.Unsorted {
+		return
+	}
+
 	// Default sort by name
 	sorting.BubbleSortLowercaseFirst(fileInfos)
 
-	// Override with time sort if requested
-	if opts.SortByTime {
+	// Override with size or time sort if requested
+	if opts.SortBySize {
+		sorting.SortSize(fileInfos)
+	} else if opts.SortByTime {
 		sorting.SortTime(fileInfos)
 	}
 
@@ -147,37 +388,6 @@ func sortFiles(fileInfos []os.FileInfo, opts Options) {
 	}
 }
 
-// printDirectory prints a directory's contents in the proper format
-func printDirectory(dir string, fileInfos []os.FileInfo, opts Options) {
-	// Get file metadata for formatting
-	metadata := CalculateFileMetadata(dir, fileInfos)
-
-	// Print total blocks if using long format
-	if opts.LongFormat {
-		var totalBlocks int64
-		for _, file := range fileInfos {
-			if stat, ok := file.Sys().(*syscall.Stat_t); ok {
-				totalBlocks += int64(stat.Blocks)
-			}
-		}
-		fmt.Printf("total %d\n", totalBlocks/2)
-	}
-
-	// Print each file
-	for _, file := range fileInfos {
-		if opts.LongFormat {
-			PrintFileInfo(dir, file, metadata.MaxSize, metadata.MaxFieldLengths)
-		} else {
-			PrintFileName(file)
-		}
-	}
-
-	// Add newline if not in long format
-	if !opts.LongFormat {
-		fmt.Println()
-	}
-}
-
 // calculateFileMetadata calculates metadata needed for formatting
 func CalculateFileMetadata(dir string, fileInfos []os.FileInfo) FileMetadata {
 	metadata := NewFileMetadata()
@@ -189,7 +399,7 @@ func CalculateFileMetadata(dir string, fileInfos []os.FileInfo) FileMetadata {
 		}
 
 		// Update field lengths
-		updateFieldLengths(dir, file, metadata.MaxFieldLengths)
+		updateFieldLengths(dir, file, Options{}, metadata.MaxFieldLengths)
 	}
 
 	return metadata
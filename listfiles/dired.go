@@ -0,0 +1,63 @@
+package listfiles
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// RenderDired prints the --dired trailer: the offsets of every name and
+// directory header written so far, and the quoting style they are in. It
+// prints nothing unless --dired applies.
+func (r *Renderer) RenderDired() {
+	if !r.diredMode() {
+		return
+	}
+	fmt.Fprintln(r.w, "//DIRED//"+formatOffsets(r.dired))
+	if len(r.subdired) > 0 {
+		fmt.Fprintln(r.w, "//SUBDIRED//"+formatOffsets(r.subdired))
+	}
+	fmt.Fprintln(r.w, "//DIRED-OPTIONS// --quoting-style="+r.quoting.String())
+}
+
+// diredMode reports whether --dired applies, which like GNU ls it only
+// does to long listings
+func (r *Renderer) diredMode() bool {
+	return r.opts.Dired && r.opts.LongFormat
+}
+
+// indent starts a --dired line with its two spaces
+func (r *Renderer) indent() {
+	if r.diredMode() {
+		fmt.Fprint(r.w, "  ")
+	}
+}
+
+// record writes text and appends its start and end offsets to offsets
+func (r *Renderer) record(offsets []int64, text string) []int64 {
+	start := r.out.n
+	fmt.Fprint(r.w, text)
+	return append(offsets, start, r.out.n)
+}
+
+// formatOffsets formats --dired offsets, each preceded by a space
+func formatOffsets(offsets []int64) string {
+	var b strings.Builder
+	for _, offset := range offsets {
+		b.WriteString(" " + strconv.FormatInt(offset, 10))
+	}
+	return b.String()
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
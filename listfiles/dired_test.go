@@ -0,0 +1,77 @@
+package listfiles
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// diredOffsets parses the offsets of a //DIRED// or //SUBDIRED// line
+func diredOffsets(t *testing.T, output, prefix string) []string {
+	t.Helper()
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, prefix+" ") {
+			continue
+		}
+		fields := strings.Fields(line)[1:]
+		for i := 0; i+1 < len(fields); i += 2 {
+			start, _ := strconv.Atoi(fields[i])
+			end, _ := strconv.Atoi(fields[i+1])
+			names = append(names, output[start:end])
+		}
+	}
+	return names
+}
+
+func TestDired(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "a file"), nil, 0644)
+	_ = os.WriteFile(filepath.Join(dir, "new\nline"), nil, 0644)
+	_ = os.Symlink("a file", filepath.Join(dir, "link"))
+	_ = os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "sub", "inner"), nil, 0644)
+
+	opts := Options{LongFormat: true, Recursive: true, Dired: true, QuotingStyle: "shell-escape"}
+	var out bytes.Buffer
+	renderer := NewRenderer(&out, opts)
+	if err := NewLister(opts).Walk(dir, renderer.RenderDirectory); err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	renderer.RenderDired()
+	output := out.String()
+
+	names := strings.Join(diredOffsets(t, output, "//DIRED//"), "|")
+	if want := "'a file'|link|'new'$'\\n''line'|sub|inner"; names != want {
+		t.Errorf("//DIRED// names = %q, want %q", names, want)
+	}
+	headers := strings.Join(diredOffsets(t, output, "//SUBDIRED//"), "|")
+	if want := dir + "|" + dir + "/sub"; headers != want {
+		t.Errorf("//SUBDIRED// headers = %q, want %q", headers, want)
+	}
+
+	// Every line of the listing is indented, and nothing is colored
+	for _, line := range strings.Split(output[:strings.Index(output, "//DIRED//")], "\n") {
+		if line != "" && !strings.HasPrefix(line, "  ") {
+			t.Errorf("Line %q is not indented", line)
+		}
+	}
+	if strings.Contains(output, "\033") {
+		t.Errorf("Output has escape sequences:\n%q", output)
+	}
+	if !strings.HasSuffix(output, "\n//DIRED-OPTIONS// --quoting-style=shell-escape\n") {
+		t.Errorf("Output missing the options line:\n%s", output)
+	}
+
+	// Without long format --dired changes nothing
+	var short bytes.Buffer
+	renderer = NewRenderer(&short, Options{Dired: true})
+	_ = NewLister(Options{}).Walk(dir, renderer.RenderDirectory)
+	renderer.RenderDired()
+	if strings.Contains(short.String(), "//DIRED") {
+		t.Errorf("Short listing has a dired trailer:\n%s", short.String())
+	}
+}
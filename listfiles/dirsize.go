@@ -0,0 +1,69 @@
+package listfiles
+
+import (
+	"os"
+
+	"go-ls-commands/sorting"
+)
+
+// applyDirSizes replaces the size of each directory among the files of dir
+// with the size of everything below it when --dir-size is set. The
+// directories are measured in name order like du measures its arguments,
+// so a file hard linked under two of them counts towards the first only.
+// The parent entry is left alone, as it holds far more than the listing.
+func (l *Lister) applyDirSizes(dir string, files []os.FileInfo) {
+	if !l.opts.DirSize {
+		return
+	}
+
+	var dirs []os.FileInfo
+	index := map[string]int{}
+	for i, file := range files {
+		if !file.IsDir() || file.Name() == ".." {
+			continue
+		}
+
+		// The directory itself holds all the others, so it is measured
+		// on its own
+		if file.Name() == "." {
+			files[i] = l.withDirSize(dir, file)
+			continue
+		}
+		dirs = append(dirs, file)
+		index[file.Name()] = i
+	}
+	if len(dirs) == 0 {
+		return
+	}
+	sorting.BubbleSortLowercaseFirst(dirs)
+
+	paths := make([]string, len(dirs))
+	for n, file := range dirs {
+		paths[n] = joinPath(dir, file.Name())
+	}
+
+	// Unreadable parts of the trees are left out
+	sizes, _ := l.sizes.Sizes(l.context(), paths...)
+	if sizes == nil {
+		return
+	}
+	for n, file := range dirs {
+		files[index[file.Name()]] = sizedFileInfo{file, sizes[n]}
+	}
+}
+
+// withDirSize returns a directory's info with the size of everything below
+// it when --dir-size is set. Unreadable parts of the tree are left out.
+func (l *Lister) withDirSize(path string, info os.FileInfo) os.FileInfo {
+	if !l.opts.DirSize || !info.IsDir() {
+		return info
+	}
+	size, _ := l.sizes.Size(l.context(), path)
+	return sizedFileInfo{info, size}
+}
+
+// TotalSize returns the combined size of the paths and everything below
+// them, counting hard linked files once, for --total
+func (l *Lister) TotalSize(paths ...string) (int64, error) {
+	return l.sizes.Size(l.context(), paths...)
+}
@@ -0,0 +1,75 @@
+package listfiles
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirSize(t *testing.T) {
+	lister := NewFSLister(testMapFS(), Options{DirSize: true, SortBySize: true})
+	dir := lister.List(".")[0]
+
+	var got []string
+	for _, entry := range dir.Entries {
+		got = append(got, entry.Name)
+		if entry.Name == "cmd" && entry.Info.Size() != 12 {
+			t.Errorf("Size of cmd = %d, want 12", entry.Info.Size())
+		}
+	}
+	if want := "cmd main.go docs README.md"; strings.Join(got, " ") != want {
+		t.Errorf("List() = %q, want %q", strings.Join(got, " "), want)
+	}
+
+	if total, err := lister.TotalSize("."); err != nil || total != 35 {
+		t.Errorf("TotalSize() = %d, %v, want 35", total, err)
+	}
+}
+
+func TestDirSizeHardLinks(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a/f"), make([]byte, 10000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(dir, "a/f"), filepath.Join(dir, "b/g")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The link counts towards a, which is measured first, and not b
+	sizes := map[string]int64{}
+	for _, entry := range NewLister(Options{DirSize: true, AllFiles: true}).List(dir)[0].Entries {
+		info, err := os.Lstat(filepath.Join(dir, entry.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes[entry.Name] = entry.Info.Size() - info.Size()
+	}
+	if sizes["a"] != 10000 || sizes["b"] != 0 {
+		t.Errorf("Contents of a and b = %d and %d, want 10000 and 0", sizes["a"], sizes["b"])
+	}
+	if sizes["."] < 10000 {
+		t.Errorf("Contents of . = %d, want at least 10000", sizes["."])
+	}
+}
+
+func TestWalkCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	lister := NewFSLister(testMapFS(), Options{Recursive: true}).WithContext(ctx)
+	called := false
+	err := lister.Walk(".", func(Directory) error {
+		called = true
+		return nil
+	})
+	if err != context.Canceled || called {
+		t.Errorf("Walk() = %v, called %v, want %v without listing", err, called, context.Canceled)
+	}
+}
@@ -0,0 +1,138 @@
+package listfiles
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// inode returns the inode number behind a file description
+func inode(t *testing.T, info os.FileInfo) uint64 {
+	t.Helper()
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		t.Fatalf("%s has no stat data", info.Name())
+	}
+	return stat.Ino
+}
+
+// checkDotEntries compares . and .. listed in dir with what the kernel
+// reports for dir/. and dir/..
+func checkDotEntries(t *testing.T, dir string) {
+	t.Helper()
+	listing := NewLister(Options{AllFiles: true}).List(dir)[0]
+	found := map[string]bool{}
+	for _, entry := range listing.Entries {
+		name := entry.Info.Name()
+		if name != "." && name != ".." {
+			continue
+		}
+		var want syscall.Stat_t
+		if err := syscall.Stat(dir+"/"+name, &want); err != nil {
+			t.Fatal(err)
+		}
+		if got := inode(t, entry.Info); got != want.Ino {
+			t.Errorf("%s in %s has inode %d, want %d", name, dir, got, want.Ino)
+		}
+		found[name] = true
+	}
+	if !found["."] || !found[".."] {
+		t.Errorf("listing of %s has dot entries %v, want both", dir, found)
+	}
+}
+
+func TestDotEntries(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "real", "target")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "elsewhere", "link")
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	checkDotEntries(t, root)
+	checkDotEntries(t, "/")
+
+	// The parent of a directory reached through a symlink is the parent
+	// of its target, not the directory holding the link
+	checkDotEntries(t, link)
+
+	// Relative paths resolve against the working directory
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(target); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	checkDotEntries(t, ".")
+	checkDotEntries(t, "..")
+}
+
+func TestTotalBlockSize(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data"), bytes.Repeat([]byte("x"), 100000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add up the blocks of everything listed with -a
+	var blocks int64
+	for _, name := range []string{".", "..", "data"} {
+		var stat syscall.Stat_t
+		if err := syscall.Stat(filepath.Join(dir, name), &stat); err != nil {
+			t.Fatal(err)
+		}
+		blocks += int64(stat.Blocks)
+	}
+	ceil := func(size int64) int64 { return (blocks*512 + size - 1) / size }
+
+	tests := []struct {
+		blockSize string
+		want      string
+		size      string
+	}{
+		{"", strconv.FormatInt(ceil(1024), 10), "100000"},
+		{"512", strconv.FormatInt(ceil(512), 10), "196"},
+		{"K", strconv.FormatInt(ceil(1024), 10) + "K", "98K"},
+		{"KB", strconv.FormatInt(ceil(1000), 10) + "kB", "100kB"},
+		{"1M", strconv.FormatInt(ceil(1<<20), 10), "1"},
+		{"MiB", strconv.FormatInt(ceil(1<<20), 10) + "MiB", "1MiB"},
+	}
+
+	for _, tt := range tests {
+		args := []string{"-la"}
+		if tt.blockSize != "" {
+			args = append(args, "--block-size="+tt.blockSize)
+		}
+		opts, err := ValidateFlags(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		renderer := NewRenderer(&out, opts)
+		for _, d := range NewLister(opts).List(dir) {
+			_ = renderer.RenderDirectory(d)
+		}
+		first, _, _ := strings.Cut(out.String(), "\n")
+		if want := "total " + tt.want; first != want {
+			t.Errorf("--block-size=%s total line = %q, want %q", tt.blockSize, first, want)
+		}
+
+		// The size column counts in the same unit
+		for _, line := range strings.Split(out.String(), "\n") {
+			if strings.HasSuffix(line, "data\033[0m") && !strings.Contains(line, " "+tt.size+" ") {
+				t.Errorf("--block-size=%s size of data missing %q:\n%s", tt.blockSize, tt.size, line)
+			}
+		}
+	}
+}
@@ -2,69 +2,165 @@ package listfiles
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/user"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
 
+	"go-ls-commands/acl"
+	"go-ls-commands/capability"
 	"go-ls-commands/colors"
+	"go-ls-commands/fsflags"
+	"go-ls-commands/xattr"
 )
 
-// FileModeToString converts file mode to string representation
+// selinuxAttr is the extended attribute holding the SELinux context of a file
+const selinuxAttr = "security.selinux"
+
+// FileModeToString converts a file mode to the string ls shows: a letter
+// for the file type followed by the read, write and execute permissions of
+// the user, group and others. Setuid and setgid show as s in place of the
+// user or group execute permission, and the sticky bit as t in place of
+// that of others, capitalized when the execute permission itself is unset.
+// Solaris doors, which ls shows as D, have no fs.FileMode bit of their own.
 func FileModeToString(mode os.FileMode) string {
-	var perm string
-
-	// Set the first character based on file type
-	if mode&os.ModeCharDevice != 0 {
-		perm = "c" // Character device
-	} else if mode&os.ModeDevice != 0 {
-		perm = "b" // Block device
-	} else if mode.IsDir() {
-		perm = "d"
-	} else if mode&os.ModeSymlink != 0 {
-		perm = "l"
-	} else {
-		perm = "-"
+	perm := []byte("----------")
+	perm[0] = fileTypeLetter(mode)
+
+	const rwx = "rwxrwxrwx"
+	for i := 0; i < 9; i++ {
+		if mode&(1<<(8-i)) != 0 {
+			perm[i+1] = rwx[i]
+		}
 	}
 
-	// Get the permission bits without the first character
-	perms := mode.Perm().String()[1:]
+	// Special bits replace the execute permission they go with
+	special := []struct {
+		bit          os.FileMode
+		pos          int
+		exec, noExec byte
+	}{
+		{os.ModeSetuid, 3, 's', 'S'},
+		{os.ModeSetgid, 6, 's', 'S'},
+		{os.ModeSticky, 9, 't', 'T'},
+	}
+	for _, sp := range special {
+		if mode&sp.bit == 0 {
+			continue
+		}
+		if perm[sp.pos] == 'x' {
+			perm[sp.pos] = sp.exec
+		} else {
+			perm[sp.pos] = sp.noExec
+		}
+	}
+
+	return string(perm)
+}
 
-	// Append the permission bits
-	perm += perms
+// fileTypeLetter returns the letter ls shows for the type of a file
+func fileTypeLetter(mode os.FileMode) byte {
+	switch {
+	case mode&os.ModeDir != 0:
+		return 'd'
+	case mode&os.ModeSymlink != 0:
+		return 'l'
+	case mode&os.ModeNamedPipe != 0:
+		return 'p'
+	case mode&os.ModeSocket != 0:
+		return 's'
+	case mode&os.ModeCharDevice != 0:
+		return 'c'
+	case mode&os.ModeDevice != 0:
+		return 'b'
+	case mode&os.ModeIrregular != 0:
+		return '?'
+	}
+	return '-'
+}
 
-	// Check for special bits
+// FileModeToOctal converts the permission and special bits of a file mode
+// to four octal digits, as chmod takes them
+func FileModeToOctal(mode os.FileMode) string {
+	bits := uint32(mode.Perm())
 	if mode&os.ModeSetuid != 0 {
-		perm = perm[:3] + "s" + perm[4:]
+		bits |= 04000
 	}
 	if mode&os.ModeSetgid != 0 {
-		perm = perm[:6] + "s" + perm[7:]
+		bits |= 02000
 	}
 	if mode&os.ModeSticky != 0 {
-		perm = perm[:9] + "t"
+		bits |= 01000
 	}
-
-	return perm
+	return fmt.Sprintf("%04o", bits)
 }
 
 // PrintFileName prints just the filename with appropriate color
 func PrintFileName(file os.FileInfo) {
-	color := colors.GetFileColor(file)
-	fmt.Printf("%s%s%s ", color, file.Name(), colors.Reset)
+	FprintFileName(os.Stdout, file)
+}
+
+// FprintFileName writes just the filename with appropriate color to w
+func FprintFileName(w io.Writer, file os.FileInfo) {
+	fmt.Fprintf(w, "%s ", coloredName(file))
+}
+
+// PrintFileNameLine prints the filename with appropriate color on a line of its own
+func PrintFileNameLine(file os.FileInfo) {
+	FprintFileNameLine(os.Stdout, file)
+}
+
+// FprintFileNameLine writes the filename with appropriate color on a line of its own to w
+func FprintFileNameLine(w io.Writer, file os.FileInfo) {
+	fmt.Fprintf(w, "%s\n", coloredName(file))
+}
+
+// coloredName returns the filename wrapped in its color
+func coloredName(file os.FileInfo) string {
+	return colors.GetFileColor(file) + file.Name() + colors.Reset
 }
 
 // PrintFileInfo prints detailed file information
 func PrintFileInfo(path string, file os.FileInfo, maxSize int64, maxFieldLengths map[string]int) {
-	stat := file.Sys().(*syscall.Stat_t)
-	owner, _ := user.LookupId(strconv.Itoa(int(stat.Uid)))
-	group, _ := user.LookupGroupId(strconv.Itoa(int(stat.Gid)))
+	FprintFileInfo(os.Stdout, path, file, maxSize, maxFieldLengths)
+}
+
+// FprintFileInfo writes detailed file information to w
+func FprintFileInfo(w io.Writer, path string, file os.FileInfo, maxSize int64, maxFieldLengths map[string]int) {
+	fprintLongEntry(w, path, file, coloredName(file), getSymlinkTarget(path, file), "", maxFieldLengths)
+}
+
+// fprintLongEntry writes detailed file information to w, given the name as
+// it should be displayed, the target of the file if it is a symlink and its
+// git status if shown
+func fprintLongEntry(w io.Writer, path string, file os.FileInfo, name, symlinkTarget, gitStatus string, maxFieldLengths map[string]int) {
+	fields := longFields(path, file, Options{}, maxFieldLengths)
+	if gitStatus != "" {
+		fields += gitStatus + " "
+	}
+
+	// Print formatted output
+	if symlinkTarget != "" {
+		fmt.Fprintf(w, "%s%s -> %s\n", fields, name, symlinkTarget)
+	} else {
+		fmt.Fprintf(w, "%s%s\n", fields, name)
+	}
+}
+
+// longFields formats the fields shown before the name in long format,
+// padded to the widths in maxFieldLengths and followed by a space. The
+// permissions are shown in the PermFormat of opts and sizes in units of
+// its BlockSize.
+func longFields(path string, file os.FileInfo, opts Options, maxFieldLengths map[string]int) string {
+	stat, hasStat := file.Sys().(*syscall.Stat_t)
+	numLinks, owner, group := statFields(file)
 
 	// Get file attributes
 	permissions := FileModeToString(file.Mode())
-	numLinks := stat.Nlink
 	modTime := file.ModTime().Format("Jan _2 15:04")
-	color := colors.GetFileColor(file)
 
 	// Construct full path for the file
 	fullPath := path
@@ -72,23 +168,34 @@ func PrintFileInfo(path string, file os.FileInfo, maxSize int64, maxFieldLengths
 		fullPath = path + "/" + file.Name()
 	}
 
-	// Check for extended attributes
+	// Mark files with an ACL, or else with a security context
+	labels := labelsOf(fullPath, file)
 	extendedAttributes := ""
-	if hasExtendedAttributes(fullPath) {
-		extendedAttributes = "+"
+	if hasStat {
+		extendedAttributes = labels.marker()
 	}
 
-	// Format permissions with extended attributes included
-	permWithExt := permissions + extendedAttributes
+	// Pad the marker so the fields after it line up, as the width is
+	// measured on the symbolic permissions whatever the format
+	padding := ""
+	if width := len(permissions) + len(extendedAttributes); width < maxFieldLengths["permissions"] {
+		padding = strings.Repeat(" ", maxFieldLengths["permissions"]-width)
+	}
 
-	// Pad permissions field to match max width
-	if len(permWithExt) < maxFieldLengths["permissions"] {
-		permWithExt = permWithExt + strings.Repeat(" ", maxFieldLengths["permissions"]-len(permWithExt))
+	// Format permissions with extended attributes included
+	var permWithExt string
+	switch opts.PermFormat {
+	case "octal":
+		permWithExt = FileModeToOctal(file.Mode()) + extendedAttributes + padding
+	case "both":
+		permWithExt = permissions + extendedAttributes + padding + " " + FileModeToOctal(file.Mode())
+	default:
+		permWithExt = permissions + extendedAttributes + padding
 	}
 
 	// Format size or device info
 	var sizeStr string
-	if file.Mode()&os.ModeDevice != 0 || file.Mode()&os.ModeCharDevice != 0 {
+	if hasStat && (file.Mode()&os.ModeDevice != 0 || file.Mode()&os.ModeCharDevice != 0) {
 	    rdev := stat.Rdev
 	    // Extract major and minor numbers.
 	    major := uint64((rdev>>8)&0xfff) | uint64((rdev>>32) & ^uint64(0xfff))
@@ -96,41 +203,188 @@ func PrintFileInfo(path string, file os.FileInfo, maxSize int64, maxFieldLengths
 	    sizeStr = fmt.Sprintf("%3d, %5d", major, minor)
 	} else {
 	    // For regular files - right align to the max field width
-	    sizeStr = fmt.Sprintf("%*d", maxFieldLengths["size"], file.Size())
+	    sizeStr = fmt.Sprintf("%*s", maxFieldLengths["size"], formatSize(file.Size(), opts))
 	}
-	
-	// Handle symlink if needed
-	symlinkTarget := getSymlinkTarget(path, file)
 
 	// Format fields with proper alignment
-	linksStr := fmt.Sprintf("%*d", maxFieldLengths["links"], numLinks)
-	ownerStr := fmt.Sprintf("%-*s", maxFieldLengths["owner"], owner.Username)
-	groupStr := fmt.Sprintf("%-*s", maxFieldLengths["group"], group.Name)
+	linksStr := fmt.Sprintf("%*s", maxFieldLengths["links"], numLinks)
+	ownerStr := fmt.Sprintf("%-*s", maxFieldLengths["owner"], owner)
+	groupStr := fmt.Sprintf("%-*s", maxFieldLengths["group"], group)
 	modTimeStr := fmt.Sprintf("%-*s", maxFieldLengths["modTime"], modTime)
 
-	// Print formatted output
-	if symlinkTarget != "" {
-		fmt.Printf("%s %s %s %s %s %s %s%s%s -> %s\n",
-			permWithExt, linksStr, ownerStr, groupStr, sizeStr, modTimeStr,
-			color, file.Name(), colors.Reset, symlinkTarget)
-	} else {
-		fmt.Printf("%s %s %s %s %s %s %s%s%s\n",
-			permWithExt, linksStr, ownerStr, groupStr, sizeStr, modTimeStr,
-			color, file.Name(), colors.Reset)
+	// The security context, inode flags and capabilities follow the group
+	// when their columns are measured
+	optionalStr := ""
+	if width, ok := maxFieldLengths["context"]; ok {
+		optionalStr += fmt.Sprintf("%-*s ", width, labels.context)
+	}
+	if width, ok := maxFieldLengths["attrs"]; ok {
+		optionalStr += fmt.Sprintf("%-*s ", width, inodeFlags(fullPath, file))
+	}
+	if width, ok := maxFieldLengths["caps"]; ok {
+		optionalStr += fmt.Sprintf("%-*s ", width, capabilities(fullPath, file))
+	}
+
+	return fmt.Sprintf("%s %s %s %s %s%s %s ",
+		permWithExt, linksStr, ownerStr, groupStr, optionalStr, sizeStr, modTimeStr)
+}
+
+// formatSize shows a size in bytes, or as a count of --block-size units
+// rounded up, followed by the unit when it was given without a number
+func formatSize(size int64, opts Options) string {
+	if opts.BlockSize == 0 {
+		return strconv.FormatInt(size, 10)
 	}
+	return strconv.FormatInt(ceilDiv(size, opts.BlockSize), 10) + opts.BlockSizeSuffix
+}
+
+// ceilDiv divides a size by a unit, rounding up
+func ceilDiv(size, unit int64) int64 {
+	return (size + unit - 1) / unit
 }
 
-// hasExtendedAttributes checks if the file has extended attributes
-func hasExtendedAttributes(path string) bool {
-	// Ensure the path is valid
-	if path == "" {
+// ownerInfo is implemented by the Sys value of files that know their owner
+// without a stat structure, such as archive members
+type ownerInfo interface {
+	Owner() string
+	Group() string
+}
+
+// statFields returns the link count, owner and group of a file. Owners and
+// groups without a name are shown by number, and each field is ? when the
+// file system does not supply it.
+func statFields(file os.FileInfo) (string, string, string) {
+	stat, ok := file.Sys().(*syscall.Stat_t)
+	if !ok {
+		// Archive members record an owner but no link count
+		if o, ok := file.Sys().(ownerInfo); ok {
+			return "?", o.Owner(), o.Group()
+		}
+		return "?", "?", "?"
+	}
+
+	owner := strconv.Itoa(int(stat.Uid))
+	if u, err := user.LookupId(owner); err == nil {
+		owner = u.Username
+	}
+	group := strconv.Itoa(int(stat.Gid))
+	if g, err := user.LookupGroupId(group); err == nil {
+		group = g.Name
+	}
+
+	return strconv.FormatUint(uint64(stat.Nlink), 10), owner, group
+}
+
+// hasACL reports whether a file has an ACL beyond its mode bits. Symlinks
+// have no ACLs of their own, and reading one would follow the link.
+func hasACL(path string, file os.FileInfo) bool {
+	if path == "" || file.Mode()&os.ModeSymlink != 0 {
 		return false
 	}
+	return acl.HasExtended(path)
+}
+
+// securityLabels holds the ACL and SELinux context of a file. Long format
+// needs them both to measure and to print each line, so entries read them
+// once and carry them in a labeledFileInfo.
+type securityLabels struct {
+	acl bool
+
+	// context is the SELinux context, or ? when there is none. It is left
+	// empty when it was not needed: for files with an ACL without -Z.
+	context string
+}
 
-	buf := make([]byte, 0)
-	size, err := syscall.Listxattr(path, buf)
+// labeledFileInfo is a file's info along with its security labels
+type labeledFileInfo struct {
+	os.FileInfo
+	labels securityLabels
+}
 
-	return err == nil && size > 0
+// readLabels reads the security labels of a file. Most files have none,
+// which a single listing of their attribute names tells. The context is
+// only read when withContext is set or the file has no ACL to mark it with.
+func readLabels(path string, file os.FileInfo, withContext bool) securityLabels {
+	if _, ok := file.Sys().(*syscall.Stat_t); ok && path != "" {
+		if names, err := xattr.List(path); err == nil && !slices.ContainsFunc(names, isLabelAttr) {
+			return securityLabels{context: "?"}
+		}
+	}
+
+	labels := securityLabels{acl: hasACL(path, file)}
+	if withContext || !labels.acl {
+		labels.context = securityContext(path, file)
+	}
+	return labels
+}
+
+// isLabelAttr reports whether an extended attribute holds an ACL or a
+// security context
+func isLabelAttr(name string) bool {
+	return name == acl.AccessAttr || name == acl.DefaultAttr || name == selinuxAttr
+}
+
+// labelsOf returns the security labels carried by file, or reads them
+func labelsOf(path string, file os.FileInfo) securityLabels {
+	if labeled, ok := file.(labeledFileInfo); ok {
+		return labeled.labels
+	}
+	return readLabels(path, file, true)
+}
+
+// marker returns the character long format adds after the permissions:
+// + for an ACL, or else . for a security context
+func (l securityLabels) marker() string {
+	if l.acl {
+		return "+"
+	}
+	if l.context != "" && l.context != "?" {
+		return "."
+	}
+	return ""
+}
+
+// securityContext returns the SELinux context of a file, or ? when it has
+// none or the file system does not record one
+func securityContext(path string, file os.FileInfo) string {
+	if _, ok := file.Sys().(*syscall.Stat_t); !ok || path == "" {
+		return "?"
+	}
+	value, err := xattr.LGet(path, selinuxAttr)
+	if err != nil || len(value) == 0 {
+		return "?"
+	}
+	return strings.TrimRight(string(value), "\x00")
+}
+
+// inodeFlags returns the inode flags of a file as lsattr shows them, or ?
+// when the file is neither a regular file nor a directory or its file
+// system has no flags
+func inodeFlags(path string, file os.FileInfo) string {
+	if _, ok := file.Sys().(*syscall.Stat_t); !ok || path == "" {
+		return "?"
+	}
+	flags, err := fsflags.Get(path)
+	if err != nil {
+		return "?"
+	}
+	return fsflags.Format(flags)
+}
+
+// capabilities returns the file capabilities of a file, - when it has none
+// or ? when they cannot be read
+func capabilities(path string, file os.FileInfo) string {
+	if _, ok := file.Sys().(*syscall.Stat_t); !ok || path == "" {
+		return "?"
+	}
+	set, err := capability.Read(path)
+	if err != nil {
+		return "?"
+	}
+	if caps := set.String(); caps != "" {
+		return caps
+	}
+	return "-"
 }
 
 // getSymlinkTarget gets the target of a symlink
@@ -171,21 +425,24 @@ func GetSymlinkTarget(path string, file os.FileInfo) (string, error) {
 	return target, nil
 }
 
-// updateFieldLengths updates the maximum field lengths map
-func updateFieldLengths(path string, file os.FileInfo, maxLengths map[string]int) {
+// updateFieldLengths updates the maximum field lengths map, measuring sizes
+// in units of the BlockSize of opts
+func updateFieldLengths(path string, file os.FileInfo, opts Options, maxLengths map[string]int) {
 	// Get stat info for user/group lookups
-	stat := file.Sys().(*syscall.Stat_t)
+	stat, hasStat := file.Sys().(*syscall.Stat_t)
+	links, owner, group := statFields(file)
 
 	// Check and update permissions length
 	permissions := FileModeToString(file.Mode())
 
-	// Account for potential '+' for extended attributes
+	// Account for potential '+' for an ACL or '.' for a security context
 	filePath := path
 	if path != file.Name() {
 		filePath = path + "/" + file.Name()
 	}
 
-	if hasExtendedAttributes(filePath) {
+	labels := labelsOf(filePath, file)
+	if hasStat && labels.marker() != "" {
 		if len(permissions)+1 > maxLengths["permissions"] {
 			maxLengths["permissions"] = len(permissions) + 1
 		}
@@ -195,26 +452,41 @@ func updateFieldLengths(path string, file os.FileInfo, maxLengths map[string]int
 		}
 	}
 
+	// Measure security contexts, inode flags and capabilities when their
+	// columns are shown
+	if _, ok := maxLengths["context"]; ok {
+		if context := labels.context; len(context) > maxLengths["context"] {
+			maxLengths["context"] = len(context)
+		}
+	}
+	if _, ok := maxLengths["attrs"]; ok {
+		if flags := inodeFlags(filePath, file); len(flags) > maxLengths["attrs"] {
+			maxLengths["attrs"] = len(flags)
+		}
+	}
+	if _, ok := maxLengths["caps"]; ok {
+		if caps := capabilities(filePath, file); len(caps) > maxLengths["caps"] {
+			maxLengths["caps"] = len(caps)
+		}
+	}
+
 	// Check and update links length
-	links := fmt.Sprintf("%d", stat.Nlink)
 	if len(links) > maxLengths["links"] {
 		maxLengths["links"] = len(links)
 	}
 
 	// Check and update owner length
-	owner, _ := user.LookupId(strconv.Itoa(int(stat.Uid)))
-	if len(owner.Username) > maxLengths["owner"] {
-		maxLengths["owner"] = len(owner.Username)
+	if len(owner) > maxLengths["owner"] {
+		maxLengths["owner"] = len(owner)
 	}
 
 	// Check and update group length
-	group, _ := user.LookupGroupId(strconv.Itoa(int(stat.Gid)))
-	if len(group.Name) > maxLengths["group"] {
-		maxLengths["group"] = len(group.Name)
+	if len(group) > maxLengths["group"] {
+		maxLengths["group"] = len(group)
 	}
 
 	// For device files, we need to simulate the exact output format that ls uses
-	if file.Mode()&os.ModeDevice != 0 || file.Mode()&os.ModeCharDevice != 0 {
+	if hasStat && (file.Mode()&os.ModeDevice != 0 || file.Mode()&os.ModeCharDevice != 0) {
 		rdev := stat.Rdev
 		major := uint64((rdev>>8)&0xfff) | uint64((rdev>>32) & ^uint64(0xfff))
 		minor := uint64(rdev&0xff) | uint64((rdev>>12) & ^uint64(0xff))
@@ -228,7 +500,7 @@ func updateFieldLengths(path string, file os.FileInfo, maxLengths map[string]int
 		}
 	} else {
 		// For regular files
-		size := fmt.Sprintf("%d", file.Size())
+		size := formatSize(file.Size(), opts)
 		if len(size) > maxLengths["size"] {
 			maxLengths["size"] = len(size)
 		}
@@ -239,9 +511,4 @@ func updateFieldLengths(path string, file os.FileInfo, maxLengths map[string]int
 	if len(modTime) > maxLengths["modTime"] {
 		maxLengths["modTime"] = len(modTime)
 	}
-
-	// Check and update filename length
-	if len(file.Name()) > maxLengths["fileName"] {
-		maxLengths["fileName"] = len(file.Name())
-	}
 }
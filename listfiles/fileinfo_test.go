@@ -1,9 +1,12 @@
 package listfiles
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 // TestUpdateFieldLengths ensures correct max field length calculations
@@ -27,7 +30,7 @@ func TestUpdateFieldLengths(t *testing.T) {
 	}
 
 	// Pass the file path as the first argument
-	updateFieldLengths(tmpFile.Name(), fileInfo, maxLengths)
+	updateFieldLengths(tmpFile.Name(), fileInfo, Options{}, maxLengths)
 
 	// Validate updates
 	if maxLengths["permissions"] < len(FileModeToString(fileInfo.Mode())) {
@@ -37,3 +40,109 @@ func TestUpdateFieldLengths(t *testing.T) {
 		t.Errorf("Size length not updated correctly")
 	}
 }
+
+// unixMode converts 12 bits of permissions as chmod takes them to a file
+// mode of the given type
+func unixMode(bits uint32, fileType os.FileMode) os.FileMode {
+	mode := fileType | os.FileMode(bits&0777)
+	if bits&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+// expectedModeString spells out the mode string of 12 permission bits
+// class by class, as a reference for FileModeToString
+func expectedModeString(bits uint32, typeLetter string) string {
+	triads := []string{"---", "--x", "-w-", "-wx", "r--", "r-x", "rw-", "rwx"}
+	specials := []struct {
+		bit        uint32
+		set, unset string
+	}{{04000, "s", "S"}, {02000, "s", "S"}, {01000, "t", "T"}}
+
+	want := typeLetter
+	for class := 0; class < 3; class++ {
+		triad := triads[bits>>(6-3*class)&7]
+		if sp := specials[class]; bits&sp.bit != 0 {
+			if triad[2] == 'x' {
+				triad = triad[:2] + sp.set
+			} else {
+				triad = triad[:2] + sp.unset
+			}
+		}
+		want += triad
+	}
+	return want
+}
+
+func TestFileModeToString(t *testing.T) {
+	types := []struct {
+		mode   os.FileMode
+		letter string
+	}{
+		{0, "-"},
+		{os.ModeDir, "d"},
+		{os.ModeSymlink, "l"},
+		{os.ModeNamedPipe, "p"},
+		{os.ModeSocket, "s"},
+		{os.ModeDevice, "b"},
+		{os.ModeDevice | os.ModeCharDevice, "c"},
+		{os.ModeCharDevice, "c"},
+		{os.ModeIrregular, "?"},
+	}
+
+	for _, typ := range types {
+		for bits := uint32(0); bits < 010000; bits++ {
+			mode := unixMode(bits, typ.mode)
+			if got, want := FileModeToString(mode), expectedModeString(bits, typ.letter); got != want {
+				t.Fatalf("FileModeToString(%v) = %s, want %s", mode, got, want)
+			}
+			if got, want := FileModeToOctal(mode), fmt.Sprintf("%04o", bits); got != want {
+				t.Fatalf("FileModeToOctal(%v) = %s, want %s", mode, got, want)
+			}
+		}
+	}
+}
+
+func TestFileModeToStringExamples(t *testing.T) {
+	tests := map[os.FileMode]string{
+		0644:                                 "-rw-r--r--",
+		os.ModeDir | os.ModeSticky | 0777:    "drwxrwxrwt",
+		os.ModeDir | os.ModeSticky | 0776:    "drwxrwxrwT",
+		os.ModeSetuid | 0755:                 "-rwsr-xr-x",
+		os.ModeSetuid | 0644:                 "-rwSr--r--",
+		os.ModeSetgid | 0755:                 "-rwxr-sr-x",
+		os.ModeSetgid | 0745:                 "-rwxr-Sr-x",
+		os.ModeNamedPipe | 0600:              "prw-------",
+		os.ModeSocket | 0755:                 "srwxr-xr-x",
+		os.ModeSymlink | 0777:                "lrwxrwxrwx",
+		os.ModeSetuid | os.ModeSetgid | 0777: "-rwsrwsrwx",
+	}
+	for mode, want := range tests {
+		if got := FileModeToString(mode); got != want {
+			t.Errorf("FileModeToString(%v) = %s, want %s", mode, got, want)
+		}
+	}
+}
+
+func TestLongFieldsPermFormat(t *testing.T) {
+	file, _ := fstest.MapFS{"f": {Mode: os.ModeSetuid | 0755}}.Stat("f")
+	maxLengths := map[string]int{"permissions": 11}
+
+	tests := map[string]string{
+		"":      "-rwsr-xr-x  ",
+		"octal": "4755  ",
+		"both":  "-rwsr-xr-x  4755 ",
+	}
+	for format, want := range tests {
+		if got := longFields("f", file, Options{PermFormat: format}, maxLengths); !strings.HasPrefix(got, want) {
+			t.Errorf("longFields() with %q = %q, want prefix %q", format, got, want)
+		}
+	}
+}
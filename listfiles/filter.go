@@ -0,0 +1,53 @@
+package listfiles
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// isExcluded reports whether an entry of dir is left out of the listing by
+// any of the filters. Gitignore files are only read from the operating
+// system's files.
+func (l *Lister) isExcluded(dir string, file os.FileInfo) bool {
+	if isFiltered(file.Name(), l.opts) {
+		return true
+	}
+	_, isOS := l.fsys.(osFS)
+	return isOS && l.isGitIgnored(dir, file)
+}
+
+// isFiltered reports whether an entry should be left out of a listing,
+// either because it is hidden or because it matches an ignore pattern
+func isFiltered(name string, opts Options) bool {
+	if !opts.ShowHidden() && strings.HasPrefix(name, ".") {
+		return true // skip hidden files if neither -a nor -A is set
+	}
+
+	// --ignore patterns apply even when -a is set
+	if matchesAny(name, opts.IgnorePatterns) {
+		return true
+	}
+
+	// --hide patterns are overridden by -a and -A
+	if !opts.ShowHidden() && matchesAny(name, opts.HidePatterns) {
+		return true
+	}
+
+	// -B skips backup files ending in ~
+	if opts.IgnoreBackups && strings.HasSuffix(name, "~") {
+		return true
+	}
+
+	return false
+}
+
+// matchesAny reports whether name matches any of the shell glob patterns
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package listfiles
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIsFiltered(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		opts     Options
+		expected bool
+	}{
+		{"Visible file", "main.go", Options{}, false},
+		{"Hidden file", ".env", Options{}, true},
+		{"Hidden file with -a", ".env", Options{AllFiles: true}, false},
+		{"Hidden file with -A", ".env", Options{AlmostAll: true}, false},
+		{"Ignore match", "main.go", Options{IgnorePatterns: []string{"*.go"}}, true},
+		{"Ignore no match", "main.c", Options{IgnorePatterns: []string{"*.go"}}, false},
+		{"Ignore wins over -a", "main.go", Options{AllFiles: true, IgnorePatterns: []string{"*.go"}}, true},
+		{"Second ignore pattern", "a.o", Options{IgnorePatterns: []string{"*.go", "*.o"}}, true},
+		{"Hide match", "main.go", Options{HidePatterns: []string{"*.go"}}, true},
+		{"Hide overridden by -a", "main.go", Options{AllFiles: true, HidePatterns: []string{"*.go"}}, false},
+		{"Hide overridden by -A", "main.go", Options{AlmostAll: true, HidePatterns: []string{"*.go"}}, false},
+		{"Backup file with -B", "notes.txt~", Options{IgnoreBackups: true}, true},
+		{"Hidden backup with -B and -a", ".notes~", Options{AllFiles: true, IgnoreBackups: true}, true},
+		{"Backup file without -B", "notes.txt~", Options{}, false},
+		{"Character class", "file1", Options{IgnorePatterns: []string{"file[0-9]"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFiltered(tt.file, tt.opts); got != tt.expected {
+				t.Errorf("isFiltered(%q) = %v, want %v", tt.file, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestIsRecursionCandidateIgnored checks that ignored directories are not descended into
+func TestIsRecursionCandidateIgnored(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.Mkdir(tmpDir+"/build", 0755)
+	_ = os.Mkdir(tmpDir+"/src", 0755)
+
+	opts := Options{IgnorePatterns: []string{"build"}}
+	for name, expected := range map[string]bool{"build": false, "src": true} {
+		info, err := os.Lstat(tmpDir + "/" + name)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		if got := NewLister(opts).isRecursionCandidate(tmpDir, info); got != expected {
+			t.Errorf("isRecursionCandidate(%q) = %v, want %v", name, got, expected)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package listfiles
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// LstatFS is a file system that can describe a symlink itself rather than
+// the file it points to
+type LstatFS interface {
+	fs.FS
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// ReadLinkFS is a file system that can read the target of a symlink
+type ReadLinkFS interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+}
+
+// listFS is the file system a Lister reads from
+type listFS interface {
+	fs.FS
+	Lstat(name string) (fs.FileInfo, error)
+	ReadLink(name string) (string, error)
+}
+
+// osFS gives access to the operating system's files through the paths used
+// on the command line, which need not be valid io/fs paths
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error)      { return os.Open(name) }
+func (osFS) Stat(name string) (fs.FileInfo, error)  { return os.Stat(name) }
+func (osFS) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+func (osFS) ReadLink(name string) (string, error)   { return os.Readlink(name) }
+
+// cleanFS adapts an io/fs.FS to command line style paths such as "./dir/",
+// forwarding the optional Lstat and ReadLink methods when they exist. When
+// the file system is mounted at a root path, such as that of an archive,
+// paths are taken relative to it. Relative paths start from dir, the
+// working directory inside the file system.
+type cleanFS struct {
+	fsys fs.FS
+	root string
+	dir  string
+}
+
+// clean turns a command line style path into a valid io/fs path. Paths that
+// leave the root, such as the parent of the root, stay at the root.
+func (c cleanFS) clean(name string) string {
+	if c.dir != "" && !strings.HasPrefix(name, "/") {
+		name = path.Join(c.dir, name)
+	}
+	name = path.Clean(name)
+	if c.root != "" {
+		root := path.Clean(c.root)
+		if name == root {
+			name = "."
+		} else if strings.HasPrefix(name, root+"/") {
+			name = name[len(root)+1:]
+		}
+	}
+
+	name = strings.TrimPrefix(name, "/")
+	if name == "" || name == ".." || strings.HasPrefix(name, "../") {
+		return "."
+	}
+	return name
+}
+
+func (c cleanFS) Open(name string) (fs.File, error) {
+	return c.fsys.Open(c.clean(name))
+}
+
+func (c cleanFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(c.fsys, c.clean(name))
+}
+
+// Lstat falls back to Stat on file systems without symlinks
+func (c cleanFS) Lstat(name string) (fs.FileInfo, error) {
+	if lfs, ok := c.fsys.(LstatFS); ok {
+		return lfs.Lstat(c.clean(name))
+	}
+	return fs.Stat(c.fsys, c.clean(name))
+}
+
+func (c cleanFS) ReadLink(name string) (string, error) {
+	if rfs, ok := c.fsys.(ReadLinkFS); ok {
+		return rfs.ReadLink(c.clean(name))
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: errors.ErrUnsupported}
+}
+
+// stat describes a file, following symlinks
+func (l *Lister) stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(l.fsys, name)
+}
+
+// lstat describes a file without following symlinks
+func (l *Lister) lstat(name string) (fs.FileInfo, error) {
+	return l.fsys.Lstat(name)
+}
+
+// readLink reads the target of a symlink
+func (l *Lister) readLink(name string) (string, error) {
+	return l.fsys.ReadLink(name)
+}
+
+// linkTargetPath returns the path of a symlink's target, which is relative
+// to the directory holding the link unless it is absolute
+func linkTargetPath(link, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return target
+	}
+	dir := path.Dir(link)
+	if dir == "." {
+		return target
+	}
+	return joinPath(dir, target)
+}
@@ -0,0 +1,251 @@
+package listfiles
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go-ls-commands/archivefs"
+	"go-ls-commands/predicate"
+)
+
+// testMapFS builds a small in-memory tree for testing
+func testMapFS() fstest.MapFS {
+	modTime := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	return fstest.MapFS{
+		"README.md":        {Data: []byte("hello"), Mode: 0644, ModTime: modTime},
+		"main.go":          {Data: []byte("package main"), Mode: 0644, ModTime: modTime},
+		".hidden":          {Data: []byte("x"), Mode: 0600, ModTime: modTime},
+		"cmd/tool/tool.go": {Data: []byte("package tool"), Mode: 0755, ModTime: modTime},
+		"docs/guide.txt":   {Data: []byte("guide"), Mode: 0644, ModTime: modTime},
+	}
+}
+
+func TestFSListerMapFS(t *testing.T) {
+	lister := NewFSLister(testMapFS(), Options{Recursive: true})
+	dirs := lister.List(".")
+
+	expected := map[string]string{
+		".":          "cmd docs main.go README.md",
+		"./cmd":      "tool",
+		"./cmd/tool": "tool.go",
+		"./docs":     "guide.txt",
+	}
+	if len(dirs) != len(expected) {
+		t.Fatalf("List() returned %d directories, want %d", len(dirs), len(expected))
+	}
+	for _, dir := range dirs {
+		if dir.Err != nil {
+			t.Fatalf("List() directory %s error = %v", dir.Path, dir.Err)
+		}
+		var names []string
+		for _, entry := range dir.Entries {
+			names = append(names, entry.Name)
+		}
+		if got := strings.Join(names, " "); got != expected[dir.Path] {
+			t.Errorf("List() directory %s = %q, want %q", dir.Path, got, expected[dir.Path])
+		}
+	}
+}
+
+func TestFSListerLongFormat(t *testing.T) {
+	opts := Options{LongFormat: true, AllFiles: true}
+
+	var buf bytes.Buffer
+	renderer := NewRenderer(&buf, opts)
+	if err := NewFSLister(testMapFS(), opts).Walk("docs/", renderer.RenderDirectory); err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+
+	// Link counts and owners are unknown to an in-memory tree
+	want := "-rw-r--r-- ? ? ? 5 Mar  1 10:30 \033[0mguide.txt\033[0m\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Output missing %q:\n%s", want, buf.String())
+	}
+	if !strings.Contains(buf.String(), "\033[34m..\033[0m\n") {
+		t.Errorf("Output missing the parent directory:\n%s", buf.String())
+	}
+}
+
+func TestFSListerZip(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, name := range []string{"a.txt", "dir/b.txt", "dir/c.txt"} {
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte(name))
+	}
+	_ = zw.Close()
+
+	zr, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open zip: %v", err)
+	}
+
+	lister := NewFSLister(zr, Options{})
+	files, dirs, errs := lister.Args([]string{"a.txt", "dir", "missing"})
+	if len(files) != 1 || len(dirs) != 1 || len(errs) != 1 {
+		t.Fatalf("Args() = %v, %v, %v", files, dirs, errs)
+	}
+
+	listing := lister.List("dir")
+	if len(listing) != 1 || len(listing[0].Entries) != 2 || listing[0].Entries[1].Name != "c.txt" {
+		t.Errorf("List(dir) = %+v", listing)
+	}
+}
+
+func TestCleanFS(t *testing.T) {
+	c := cleanFS{}
+	tests := map[string]string{
+		".":      ".",
+		"./":     ".",
+		"./a/b/": "a/b",
+		"a//b":   "a/b",
+		"a/../b": "b",
+		"..":     ".",
+		"./..":   ".",
+		"../a":   ".",
+		"/a":     "a",
+		"/":      ".",
+	}
+
+	for input, expected := range tests {
+		if got := c.clean(input); got != expected {
+			t.Errorf("clean(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
+
+func TestFSListerAtArchive(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	modTime := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	_ = tw.WriteHeader(&tar.Header{Name: "inner/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: modTime})
+	_ = tw.WriteHeader(&tar.Header{Name: "inner/run", Typeflag: tar.TypeReg, Mode: 0700, ModTime: modTime, Uname: "alice", Gname: "staff"})
+	_ = tw.WriteHeader(&tar.Header{Name: "inner/cur", Typeflag: tar.TypeSymlink, Linkname: "run", Mode: 0777, ModTime: modTime, Uname: "alice", Gname: "staff"})
+	_ = tw.Close()
+
+	fsys, err := archivefs.ReadTar(&buf, modTime)
+	if err != nil {
+		t.Fatalf("ReadTar() error = %v", err)
+	}
+
+	opts := Options{LongFormat: true}
+	lister := NewFSListerAt(fsys, "build.tar", opts)
+	_, dirs, errs := lister.Args([]string{"build.tar/inner"})
+	if len(dirs) != 1 || len(errs) != 0 {
+		t.Fatalf("Args() = %v, %v", dirs, errs)
+	}
+
+	var out bytes.Buffer
+	renderer := NewRenderer(&out, opts)
+	renderer.ShowHeaders = true
+	_ = lister.Walk(dirs[0], renderer.RenderDirectory)
+
+	for _, want := range []string{
+		"build.tar/inner:\n",
+		"-rwx------ ? alice staff 0 Mar  1 10:30 \033[32mrun\033[0m\n",
+		"lrwxrwxrwx ? alice staff 3 Mar  1 10:30 \033[0mcur\033[0m -> run\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestFSListerEmpty(t *testing.T) {
+	fsys := fstest.MapFS{
+		"s/emptydir":  {Mode: fs.ModeDir | 0755},
+		"s/full/file": {Data: []byte("data")},
+		"s/zero":      {},
+	}
+	empty, err := predicate.Parse("empty", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The directories are only in the archive, not on disk
+	lister := NewFSListerAt(fsys, "t.tar", Options{Predicates: []predicate.Predicate{empty}})
+	var got []string
+	for _, entry := range lister.List("t.tar/s")[0].Entries {
+		got = append(got, entry.Name)
+	}
+	if want := "emptydir zero"; strings.Join(got, " ") != want {
+		t.Errorf("List() with --empty = %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+func TestFSListerPredicatesDotEntries(t *testing.T) {
+	fsys := fstest.MapFS{
+		"b.go":     {},
+		"a.go":     {},
+		"sub/c.go": {},
+	}
+	regular, err := predicate.Parse("type", "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// . and .. are filtered like other entries, and unlisted directories
+	// are still recursed into
+	for _, unsorted := range []bool{false, true} {
+		opts := Options{AllFiles: true, Recursive: true, Unsorted: unsorted, Predicates: []predicate.Predicate{regular}}
+		var got []string
+		for _, dir := range NewFSLister(fsys, opts).List(".") {
+			for _, entry := range dir.Entries {
+				got = append(got, entry.Path)
+			}
+		}
+		sort.Strings(got)
+		if want := "./a.go ./b.go ./sub/c.go"; strings.Join(got, " ") != want {
+			t.Errorf("List() with --type=f and unsorted %v = %q, want %q", unsorted, strings.Join(got, " "), want)
+		}
+	}
+}
+
+func TestCleanFSIn(t *testing.T) {
+	c := cleanFS{root: "/repo", dir: "sub"}
+	tests := map[string]string{
+		".":             "sub",
+		"a":             "sub/a",
+		"..":            ".",
+		"../other":      "other",
+		"../..":         ".",
+		"/repo":         ".",
+		"/repo/other/x": "other/x",
+	}
+
+	for input, expected := range tests {
+		if got := c.clean(input); got != expected {
+			t.Errorf("clean(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
+
+func TestFSListerIn(t *testing.T) {
+	fsys := fstest.MapFS{
+		"top.txt":       {Data: []byte("x")},
+		"sub/inner.txt": {Data: []byte("y")},
+	}
+	lister := NewFSListerIn(fsys, "/repo", "sub", Options{})
+
+	expected := map[string]string{
+		".":               "inner.txt",
+		"..":              "sub top.txt",
+		"/repo/sub/../..": "sub top.txt",
+	}
+	for path, want := range expected {
+		var names []string
+		for _, entry := range lister.List(path)[0].Entries {
+			names = append(names, entry.Name)
+		}
+		if got := strings.Join(names, " "); got != want {
+			t.Errorf("List(%s) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package listfiles
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go-ls-commands/gitignore"
+)
+
+// gitIgnoreState holds the repository root and the patterns that apply
+// inside one directory
+type gitIgnoreState struct {
+	root    string
+	matcher *gitignore.Matcher
+}
+
+// gitIgnoreCache holds the state of each directory a lister visited, so
+// every gitignore file is only read once during a recursive listing. It is
+// shared by the copies of a lister and safe for concurrent use.
+type gitIgnoreCache struct {
+	mu     sync.Mutex
+	states map[string]gitIgnoreState
+}
+
+// isGitIgnored reports whether an entry of dir is ignored by git when
+// --gitignore is set. Outside a git working tree nothing is ignored.
+func (l *Lister) isGitIgnored(dir string, file os.FileInfo) bool {
+	if !l.opts.GitIgnore {
+		return false
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+
+	l.gitIgnores.mu.Lock()
+	state := l.gitIgnores.stateFor(absDir)
+	l.gitIgnores.mu.Unlock()
+	if state.matcher == nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(state.root, filepath.Join(absDir, file.Name()))
+	if err != nil {
+		return false
+	}
+	return state.matcher.Match(filepath.ToSlash(rel), file.IsDir())
+}
+
+// stateFor builds the state of a directory from that of its parent and its
+// own .gitignore file. The cache must be locked.
+func (c *gitIgnoreCache) stateFor(absDir string) gitIgnoreState {
+	if c.states == nil {
+		c.states = map[string]gitIgnoreState{}
+	}
+	if state, ok := c.states[absDir]; ok {
+		return state
+	}
+
+	var state gitIgnoreState
+	root, ok := gitignore.FindRepoRoot(absDir)
+	if ok && root == absDir {
+		state.root = root
+		state.matcher = gitignore.NewRepoMatcher(root).With(gitignore.ReadFile(filepath.Join(root, ".gitignore"), ""))
+	} else if ok {
+		parent := c.stateFor(filepath.Dir(absDir))
+		rel, _ := filepath.Rel(root, absDir)
+		state.root = root
+		state.matcher = parent.matcher.With(gitignore.ReadFile(filepath.Join(absDir, ".gitignore"), filepath.ToSlash(rel)))
+	}
+
+	c.states[absDir] = state
+	return state
+}
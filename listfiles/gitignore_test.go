@@ -0,0 +1,73 @@
+package listfiles
+
+import (
+	"os"
+	"testing"
+)
+
+// TestIsGitIgnored checks that gitignore files are applied hierarchically
+func TestIsGitIgnored(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.MkdirAll(tmpDir+"/.git/info", 0755)
+	_ = os.MkdirAll(tmpDir+"/src/gen", 0755)
+	_ = os.MkdirAll(tmpDir+"/build", 0755)
+	_ = os.WriteFile(tmpDir+"/.gitignore", []byte("build/\n*.log\n"), 0644)
+	_ = os.WriteFile(tmpDir+"/.git/info/exclude", []byte("secret.txt\n"), 0644)
+	_ = os.WriteFile(tmpDir+"/src/.gitignore", []byte("/gen\n!keep.log\n"), 0644)
+	for _, name := range []string{"a.log", "main.go", "secret.txt", "src/keep.log", "src/b.log", "src/c.go"} {
+		_ = os.WriteFile(tmpDir+"/"+name, nil, 0644)
+	}
+
+	tests := []struct {
+		dir      string
+		name     string
+		expected bool
+	}{
+		{tmpDir, "a.log", true},
+		{tmpDir, "main.go", false},
+		{tmpDir, "secret.txt", true},
+		{tmpDir, "build", true},
+		{tmpDir, "src", false},
+		{tmpDir + "/src", "keep.log", false},
+		{tmpDir + "/src", "b.log", true},
+		{tmpDir + "/src", "c.go", false},
+		{tmpDir + "/src", "gen", true},
+	}
+
+	lister := NewLister(Options{GitIgnore: true})
+	plain := NewLister(Options{})
+	for _, tt := range tests {
+		info, err := os.Lstat(tt.dir + "/" + tt.name)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", tt.name, err)
+		}
+		if got := lister.isGitIgnored(tt.dir, info); got != tt.expected {
+			t.Errorf("isGitIgnored(%q) = %v, want %v", tt.name, got, tt.expected)
+		}
+		if plain.isGitIgnored(tt.dir, info) {
+			t.Errorf("isGitIgnored(%q) without --gitignore = true", tt.name)
+		}
+	}
+}
+
+// TestGitIgnoreCachePerLister checks that a new lister rereads gitignore
+// files changed since an earlier one read them
+func TestGitIgnoreCachePerLister(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.MkdirAll(tmpDir+"/.git", 0755)
+	_ = os.WriteFile(tmpDir+"/.gitignore", []byte("*.log\n"), 0644)
+	_ = os.WriteFile(tmpDir+"/a.log", nil, 0644)
+	info, err := os.Lstat(tmpDir + "/a.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{GitIgnore: true}
+	if !NewLister(opts).isGitIgnored(tmpDir, info) {
+		t.Fatalf("isGitIgnored(a.log) = false, want true")
+	}
+	_ = os.WriteFile(tmpDir+"/.gitignore", nil, 0644)
+	if NewLister(opts).isGitIgnored(tmpDir, info) {
+		t.Errorf("isGitIgnored(a.log) with a new lister = true after the pattern was removed")
+	}
+}
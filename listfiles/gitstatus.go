@@ -0,0 +1,68 @@
+package listfiles
+
+import (
+	"path/filepath"
+
+	"go-ls-commands/gitrev"
+)
+
+// noGitStatus fills the status column of files outside any working tree
+const noGitStatus = "  "
+
+// gitStatus returns the two character git status of a file for --git, or
+// nothing when the column is not shown. Only the operating system's files
+// have a working tree to compare with.
+func (l *Lister) gitStatus(path string) string {
+	if !l.opts.GitStatus {
+		return ""
+	}
+	if _, ok := l.fsys.(osFS); !ok {
+		return noGitStatus
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return noGitStatus
+	}
+
+	// A file belongs to the working tree of its directory, except for the
+	// top of a working tree listed as "."
+	status := l.repoStatus(filepath.Dir(abs))
+	if status == nil {
+		status = l.repoStatus(abs)
+	}
+	if status == nil {
+		return noGitStatus
+	}
+	if s := status.Of(abs); s != "" {
+		return s
+	}
+	return noGitStatus
+}
+
+// repoStatus returns the status of the working tree holding dir, reading
+// each repository's index and HEAD once
+func (l *Lister) repoStatus(dir string) *gitrev.Status {
+	if l.gitRoots == nil {
+		l.gitRoots = map[string]string{}
+		l.gitStatuses = map[string]*gitrev.Status{}
+	}
+
+	root, ok := l.gitRoots[dir]
+	if !ok {
+		var gitDir string
+		var err error
+		root, gitDir, err = gitrev.Discover(dir)
+		if err == nil {
+			if _, ok := l.gitStatuses[root]; !ok {
+				var status *gitrev.Status
+				if repo, err := gitrev.Open(gitDir); err == nil {
+					status, _ = repo.Status(root)
+				}
+				l.gitStatuses[root] = status
+			}
+		}
+		l.gitRoots[dir] = root
+	}
+	return l.gitStatuses[root]
+}
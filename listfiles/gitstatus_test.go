@@ -0,0 +1,76 @@
+package listfiles
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitStatusColumn(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// A fresh repository has nothing tracked, so every file is untracked
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		".git/HEAD":  "ref: refs/heads/main\n",
+		".gitignore": "*.log\n",
+		"main.go":    "package main\n",
+		"debug.log":  "x",
+		"pkg/lib.go": "package pkg\n",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := Options{GitStatus: true, OnePerLine: true}
+	var out bytes.Buffer
+	renderer := NewRenderer(&out, opts)
+	for _, dir := range NewLister(opts).List(root) {
+		_ = renderer.RenderDirectory(dir)
+	}
+
+	plain := strings.NewReplacer("\033[0m", "", "\033[34m", "").Replace(out.String())
+	for _, want := range []string{"!! debug.log\n", "?? main.go\n", "?? pkg\n"} {
+		if !strings.Contains(plain, want) {
+			t.Errorf("Output missing %q:\n%s", want, plain)
+		}
+	}
+
+	// Files outside any working tree, or on other file systems, get a blank
+	// column to keep alignment
+	lister := NewLister(opts)
+	if got := lister.gitStatus(os.TempDir()); got != noGitStatus {
+		t.Errorf("gitStatus(%s) = %q", os.TempDir(), got)
+	}
+	if got := NewFSLister(testMapFS(), opts).gitStatus("main.go"); got != noGitStatus {
+		t.Errorf("gitStatus() on an io/fs.FS = %q, want %q", got, noGitStatus)
+	}
+}
+
+func TestGitStatusLongFormat(t *testing.T) {
+	opts := Options{LongFormat: true}
+	var out bytes.Buffer
+	entry := Entry{Name: "main.go", Info: testMapFSInfo(t, "main.go"), GitStatus: "M-"}
+	NewRenderer(&out, opts).RenderFiles([]Entry{entry})
+
+	if !strings.HasSuffix(out.String(), " M- \033[0mmain.go\033[0m\n") {
+		t.Errorf("RenderFiles() = %q, want the status before the name", out.String())
+	}
+}
+
+// testMapFSInfo describes a file of the test file system
+func testMapFSInfo(t *testing.T, name string) os.FileInfo {
+	t.Helper()
+	info, err := testMapFS().Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
@@ -0,0 +1,60 @@
+package listfiles
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"go-ls-commands/colors"
+)
+
+// displayName returns the name of an entry as printed: quoted, in its color
+// and, with --hyperlink, wrapped in an OSC 8 escape sequence linking to the
+// file so terminals can open it on click. Column widths are measured on the
+// quoted name, since terminals show nothing for the escape sequences.
+func (r *Renderer) displayName(entry Entry) string {
+	name := colors.GetFileColor(entry.Info) + r.quote(entry.Info.Name()) + colors.Reset
+	if !r.hyperlinks || entry.AbsPath == "" {
+		return name
+	}
+	return hyperlink(fileURI(r.hostname, entry.AbsPath), name)
+}
+
+// hyperlink wraps text in the OSC 8 sequences that make it a link
+func hyperlink(uri, text string) string {
+	return "\033]8;;" + uri + "\033\\" + text + "\033]8;;\033\\"
+}
+
+// fileURI returns the file:// URI of an absolute path on a host
+func fileURI(hostname, path string) string {
+	return "file://" + percentEncode(hostname) + percentEncode(path)
+}
+
+// percentEncode escapes every byte of s but unreserved characters and
+// slashes, as needed in the path of a URI
+func percentEncode(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
+
+// isTerminal reports whether w writes to a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,75 @@
+package listfiles
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFileURI(t *testing.T) {
+	tests := []struct {
+		host, path, want string
+	}{
+		{"box", "/home/user/file.txt", "file://box/home/user/file.txt"},
+		{"box", "/tmp/with space", "file://box/tmp/with%20space"},
+		{"box", "/tmp/100%#?&", "file://box/tmp/100%25%23%3F%26"},
+		{"box", "/tmp/caf\u00e9", "file://box/tmp/caf%C3%A9"},
+		{"box", "/tmp/new\nline", "file://box/tmp/new%0Aline"},
+		{"", "/a~b_c-d.e", "file:///a~b_c-d.e"},
+	}
+	for _, tt := range tests {
+		if got := fileURI(tt.host, tt.path); got != tt.want {
+			t.Errorf("fileURI(%q, %q) = %q, want %q", tt.host, tt.path, got, tt.want)
+		}
+	}
+}
+
+// osc8 matches the escape sequences that open and close a hyperlink
+var osc8 = regexp.MustCompile("\033]8;;[^\033]*\033\\\\")
+
+func TestHyperlinks(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a file", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	render := func(opts Options) string {
+		var out bytes.Buffer
+		renderer := NewRenderer(&out, opts)
+		renderer.hostname = "box"
+		for _, d := range NewLister(opts).List(dir) {
+			_ = renderer.RenderDirectory(d)
+		}
+		return out.String()
+	}
+
+	linked := render(Options{LongFormat: true, Hyperlink: "always"})
+	want := "\033]8;;file://box" + percentEncode(filepath.Join(dir, "a file")) + "\033\\\033[0ma file\033[0m\033]8;;\033\\\n"
+	if !strings.Contains(linked, want) {
+		t.Errorf("Output missing %q:\n%q", want, linked)
+	}
+
+	// Links add nothing visible, so columns line up as without them
+	plain := render(Options{LongFormat: true})
+	if osc8.ReplaceAllString(linked, "") != plain {
+		t.Errorf("Output with links differs once they are removed:\n%q\n%q", linked, plain)
+	}
+
+	// A buffer is no terminal, and files of other file systems have no URI
+	if got := render(Options{Hyperlink: "auto"}); osc8.MatchString(got) {
+		t.Errorf("--hyperlink=auto linked names when not on a terminal: %q", got)
+	}
+	var out bytes.Buffer
+	opts := Options{Hyperlink: "always", OnePerLine: true}
+	for _, d := range NewFSLister(testMapFS(), opts).List(".") {
+		_ = NewRenderer(&out, opts).RenderDirectory(d)
+	}
+	if osc8.MatchString(out.String()) {
+		t.Errorf("Names of an io/fs.FS were linked: %q", out.String())
+	}
+}
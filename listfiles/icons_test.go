@@ -0,0 +1,45 @@
+package listfiles
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go-ls-commands/icons"
+)
+
+func TestIconColumn(t *testing.T) {
+	table := icons.Default()
+	if err := table.Parse(strings.NewReader("ext.go = \U0001F439\nname.README.md = M\ntype.di =\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{OnePerLine: true, Icons: "always"}
+	var out bytes.Buffer
+	renderer := NewRenderer(&out, opts)
+	renderer.Icons = table
+	for _, dir := range NewFSLister(testMapFS(), opts).List(".") {
+		_ = renderer.RenderDirectory(dir)
+	}
+
+	// The wide icon sets the width of the column, and directories without
+	// an icon are padded to it
+	want := "   \033[34mcmd\033[0m\n" +
+		"   \033[34mdocs\033[0m\n" +
+		"\U0001F439 \033[0mmain.go\033[0m\n" +
+		"M  \033[0mREADME.md\033[0m\n"
+	if out.String() != want {
+		t.Errorf("RenderDirectory() =\n%q\nwant\n%q", out.String(), want)
+	}
+}
+
+func TestIconsOff(t *testing.T) {
+	for _, opts := range []Options{{}, {Icons: "never"}, {Icons: "auto"}} {
+		if r := NewRenderer(&bytes.Buffer{}, opts); r.Icons != nil {
+			t.Errorf("NewRenderer(%q) shows icons when writing to a buffer", opts.Icons)
+		}
+	}
+	if r := NewRenderer(&bytes.Buffer{}, Options{Icons: "always"}); r.Icons == nil {
+		t.Errorf("NewRenderer(always) shows no icons")
+	}
+}
@@ -0,0 +1,66 @@
+package listfiles
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRendererQuoting(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"new\nline", "plain"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("tab\there", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(opts Options) string {
+		var out bytes.Buffer
+		renderer := NewRenderer(&out, opts)
+		for _, d := range NewLister(opts).List(dir) {
+			_ = renderer.RenderDirectory(d)
+		}
+		return out.String()
+	}
+
+	tests := []struct {
+		opts     Options
+		contains []string
+	}{
+		// Output to a pipe is literal by default
+		{Options{OnePerLine: true}, []string{"\033[0mnew\nline\033[0m\n"}},
+		{Options{OnePerLine: true, HideControlChars: true}, []string{"\033[0mnew?line\033[0m\n"}},
+		{Options{OnePerLine: true, QuotingStyle: "escape"}, []string{"\033[0mnew\\nline\033[0m\n"}},
+
+		// Names without quotes are shifted to line up with quoted ones, and
+		// symlink targets are quoted too
+		{Options{LongFormat: true, QuotingStyle: "shell-escape"}, []string{
+			" \033[0m'new'$'\\n''line'\033[0m\n",
+			"  \033[0mplain\033[0m\n",
+			"link\033[0m -> 'tab'$'\\t''here'\n",
+		}},
+		{Options{OnePerLine: true, QuotingStyle: "c"}, []string{"\033[0m\"plain\"\033[0m\n"}},
+	}
+
+	for _, tt := range tests {
+		output := render(tt.opts)
+		for _, want := range tt.contains {
+			if !strings.Contains(output, want) {
+				t.Errorf("Output with %+v missing %q:\n%q", tt.opts, want, output)
+			}
+		}
+	}
+
+	// The name column is measured on quoted names
+	metadata := NewFileMetadata()
+	renderer := NewRenderer(&bytes.Buffer{}, Options{QuotingStyle: "shell-escape"})
+	renderer.measureNames(NewLister(Options{}).List(dir)[0].Entries, metadata.MaxFieldLengths)
+	if got := metadata.MaxFieldLengths["fileName"]; got != len("'new'$'\\n''line'") {
+		t.Errorf("fileName width = %d, want %d", got, len("'new'$'\\n''line'"))
+	}
+}
@@ -0,0 +1,432 @@
+package listfiles
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"go-ls-commands/acl"
+	"go-ls-commands/icons"
+	"go-ls-commands/quoting"
+	"go-ls-commands/xattr"
+)
+
+// Renderer writes listings to any writer in the format chosen by the options
+type Renderer struct {
+	w    io.Writer
+	opts Options
+
+	// out counts the bytes written to w, which --dired offsets refer to
+	out *countingWriter
+
+	// dired and subdired hold the start and end offsets of each name and
+	// directory header written, for --dired
+	dired, subdired []int64
+
+	// ShowHeaders prints a "path:" header above each directory, as done
+	// when listing several paths or recursing
+	ShowHeaders bool
+
+	wrote    bool
+	metadata FileMetadata
+
+	// totalBlocks adds up the blocks of the directory being printed, over
+	// all its batches when it is streamed
+	totalBlocks int64
+
+	// Icons picks the icon shown before each name, or is nil when icons
+	// are off
+	Icons *icons.Table
+
+	// quoting is how names are quoted, and hideControl replaces their
+	// unprintable characters with ?
+	quoting     quoting.Style
+	hideControl bool
+
+	// hyperlinks is set when names link to their files, on hostname
+	hyperlinks bool
+	hostname   string
+
+	// findings holds what --audit found, for the summary report, which
+	// is written to report. With --audit=json the report is written alone,
+	// and the listing is discarded so that the output parses as JSON.
+	findings []Finding
+	report   io.Writer
+
+	// Counts of the directories and files shown in tree listings
+	treeDirs, treeFiles int
+}
+
+// NewRenderer creates a renderer writing to w
+func NewRenderer(w io.Writer, opts Options) *Renderer {
+	listing := w
+	if opts.Audit == "json" {
+		listing = io.Discard
+	}
+	out := &countingWriter{w: listing}
+	r := &Renderer{w: out, out: out, report: w, opts: opts, ShowHeaders: opts.Recursive, hideControl: opts.HideControlChars}
+	if opts.QuotingStyle != "" {
+		r.quoting, _ = quoting.ParseStyle(opts.QuotingStyle)
+	} else if isTerminal(w) {
+		r.quoting = quoting.ShellEscape
+	}
+	if r.diredMode() {
+		// Offsets are for Emacs, which shows neither colors nor links
+		return r
+	}
+	if opts.Hyperlink == "always" || opts.Hyperlink == "auto" && isTerminal(w) {
+		r.hyperlinks = true
+		r.hostname, _ = os.Hostname()
+	}
+	if opts.Icons == "always" || opts.Icons == "auto" && isTerminal(w) {
+		r.Icons = icons.Default()
+	}
+	return r
+}
+
+// RenderFiles prints files named on the command line, aligned as a group
+func (r *Renderer) RenderFiles(entries []Entry) {
+	if len(entries) == 0 {
+		return
+	}
+	r.wrote = true
+
+	metadata := r.newMetadata()
+	r.measureNames(entries, metadata.MaxFieldLengths)
+	r.measureIcons(entries, metadata.MaxFieldLengths)
+	if !r.opts.LongFormat {
+		r.measureContexts(entries, metadata.MaxFieldLengths)
+	}
+	if r.opts.LongFormat {
+		for _, entry := range entries {
+			if entry.Info.Size() > metadata.MaxSize {
+				metadata.MaxSize = entry.Info.Size()
+			}
+			updateFieldLengths(entry.Name, entry.Info, r.opts, metadata.MaxFieldLengths)
+		}
+	}
+
+	for _, entry := range entries {
+		r.printEntry(entry.Name, entry, metadata)
+	}
+
+	if !r.opts.LongFormat && !r.opts.OnePerLine {
+		fmt.Fprintln(r.w)
+	}
+}
+
+// RenderGrandTotal prints the combined size of everything listed, for --total
+func (r *Renderer) RenderGrandTotal(size int64) {
+	fmt.Fprintf(r.w, "grand total %d\n", size)
+}
+
+// RenderDirectory prints the listing of a directory. It has the signature of
+// a Lister.Walk callback, and never fails itself.
+func (r *Renderer) RenderDirectory(dir Directory) error {
+	if !dir.Continued {
+		if r.ShowHeaders {
+			if r.wrote {
+				fmt.Fprintln(r.w)
+			}
+			r.indent()
+			r.subdired = r.record(r.subdired, r.quote(dir.Path))
+			fmt.Fprintln(r.w, ":")
+		}
+		r.wrote = true
+		r.metadata = r.newMetadata()
+		r.totalBlocks = 0
+	}
+
+	if dir.Err != nil {
+		fmt.Fprintln(r.w, "Error: ", dir.Err)
+		return nil
+	}
+
+	r.printDirectory(dir)
+	return nil
+}
+
+// printDirectory prints a directory's contents in the proper format. Column
+// widths are carried over between the batches of a streamed directory and
+// only ever grow. The block count of a streamed directory is unknown until
+// its last batch, so its total line follows the entries instead of heading
+// them.
+func (r *Renderer) printDirectory(dir Directory) {
+	r.measureNames(dir.Entries, r.metadata.MaxFieldLengths)
+	r.measureIcons(dir.Entries, r.metadata.MaxFieldLengths)
+	if !r.opts.LongFormat {
+		r.measureContexts(dir.Entries, r.metadata.MaxFieldLengths)
+	}
+	if r.opts.LongFormat {
+		for _, entry := range dir.Entries {
+			if entry.Info.Size() > r.metadata.MaxSize {
+				r.metadata.MaxSize = entry.Info.Size()
+			}
+			updateFieldLengths(dir.Path, entry.Info, r.opts, r.metadata.MaxFieldLengths)
+		}
+
+		// Print total blocks if using long format
+		for _, entry := range dir.Entries {
+			if stat, ok := entry.Info.Sys().(*syscall.Stat_t); ok {
+				r.totalBlocks += int64(stat.Blocks)
+			}
+		}
+		if !dir.Continued && !dir.Partial {
+			r.printTotal()
+		}
+	}
+
+	// Print each file
+	for _, entry := range dir.Entries {
+		r.printEntry(dir.Path, entry, r.metadata)
+	}
+
+	if r.opts.LongFormat && dir.Continued && !dir.Partial {
+		r.printTotal()
+	}
+
+	// Add newline if not in long format
+	if !dir.Partial && !r.opts.LongFormat && !r.opts.OnePerLine {
+		fmt.Fprintln(r.w)
+	}
+}
+
+// printTotal prints the total line of the directory being printed
+func (r *Renderer) printTotal() {
+	r.indent()
+	fmt.Fprintf(r.w, "total %s\n", r.formatBlocks(r.totalBlocks))
+}
+
+// printEntry prints a single entry in the proper format
+func (r *Renderer) printEntry(dir string, entry Entry, metadata FileMetadata) {
+	if r.opts.LongFormat {
+		r.indent()
+		fmt.Fprint(r.w, longFields(dir, entry.Info, r.opts, metadata.MaxFieldLengths))
+		if entry.GitStatus != "" {
+			fmt.Fprint(r.w, entry.GitStatus+" ")
+		}
+		fmt.Fprint(r.w, r.iconPrefix(entry.Info, metadata.MaxFieldLengths), r.alignQuotes(entry, metadata.MaxFieldLengths))
+		if r.diredMode() {
+			r.dired = r.record(r.dired, r.quote(entry.Info.Name()))
+		} else {
+			fmt.Fprint(r.w, r.displayName(entry))
+		}
+		if target := r.linkTarget(entry); target != "" {
+			fmt.Fprint(r.w, " -> "+target)
+		}
+		fmt.Fprintln(r.w, r.auditNote(entry))
+		if r.opts.ShowACL {
+			r.printACL(entry)
+		}
+		if r.opts.Xattrs {
+			r.printXattrs(entry)
+		}
+		return
+	}
+
+	// The git status, security context and icon go in front of the name
+	if entry.GitStatus != "" {
+		fmt.Fprint(r.w, entry.GitStatus+" ")
+	}
+	if r.opts.Context {
+		fmt.Fprintf(r.w, "%*s ", metadata.MaxFieldLengths["context"], labelsOf(entry.Path, entry.Info).context)
+	}
+	fmt.Fprint(r.w, r.iconPrefix(entry.Info, metadata.MaxFieldLengths), r.alignQuotes(entry, metadata.MaxFieldLengths))
+	if r.opts.OnePerLine {
+		fmt.Fprintf(r.w, "%s%s\n", r.displayName(entry), r.auditNote(entry))
+	} else {
+		fmt.Fprintf(r.w, "%s%s ", r.displayName(entry), r.auditNote(entry))
+	}
+}
+
+// newMetadata creates the column widths of a listing, with columns for
+// security contexts, inode flags and capabilities when they are shown
+func (r *Renderer) newMetadata() FileMetadata {
+	metadata := NewFileMetadata()
+	if r.opts.Context {
+		metadata.MaxFieldLengths["context"] = 0
+	}
+	if r.opts.Attrs && r.opts.LongFormat {
+		metadata.MaxFieldLengths["attrs"] = 0
+	}
+	if r.opts.Caps && r.opts.LongFormat {
+		metadata.MaxFieldLengths["caps"] = 0
+	}
+	return metadata
+}
+
+// formatBlocks converts a count of 512 byte blocks to the unit of
+// --block-size, rounding up as GNU ls does
+func (r *Renderer) formatBlocks(blocks int64) string {
+	unit := r.opts.BlockSize
+	if unit == 0 {
+		unit = 1024
+	}
+	return strconv.FormatInt(ceilDiv(blocks*512, unit), 10) + r.opts.BlockSizeSuffix
+}
+
+// RenderError prints an error about a path, counted in --dired offsets
+func (r *Renderer) RenderError(err error) {
+	if r.opts.Audit == "json" {
+		// Keep the JSON report alone on the output
+		fmt.Fprintf(os.Stderr, "ls: %v\n", err)
+		return
+	}
+	fmt.Fprintf(r.w, "ls: %v\n", err)
+}
+
+// printACL prints the ACL entries of a file with an ACL beyond its mode
+// bits, indented under its line, with those of the default ACL of a
+// directory prefixed by default: as getfacl does
+func (r *Renderer) printACL(entry Entry) {
+	if _, ok := entry.Info.Sys().(*syscall.Stat_t); !ok || entry.Info.Mode()&os.ModeSymlink != 0 {
+		return
+	}
+	access, defaults, err := acl.Read(entry.Path)
+	if err != nil || !access.Extended() && len(defaults) == 0 {
+		return
+	}
+	for _, line := range append(access.Lines(""), defaults.Lines("default:")...) {
+		r.indent()
+		fmt.Fprintln(r.w, "    "+line)
+	}
+}
+
+// printXattrs prints the extended attributes of a file indented under its
+// line, each with the size of its value and, if asked for, the value in
+// hex or quoted. A file whose attributes cannot be read gets the reason
+// instead, unless its file system has none.
+func (r *Renderer) printXattrs(entry Entry) {
+	if _, ok := entry.Info.Sys().(*syscall.Stat_t); !ok {
+		return
+	}
+	names, err := xattr.List(entry.Path)
+	if err != nil {
+		if !xattr.IsMissing(err) {
+			r.indent()
+			fmt.Fprintf(r.w, "    <cannot list attributes: %v>\n", err)
+		}
+		return
+	}
+
+	// Values are read up front to align the names and sizes, and listed
+	// by name since file systems keep attributes in no particular order
+	slices.Sort(names)
+	type attr struct {
+		name, size, value string
+	}
+	attrs := make([]attr, 0, len(names))
+	nameWidth, sizeWidth := 0, 0
+	for _, name := range names {
+		a := attr{name: r.quote(name)}
+		if value, err := xattr.LGet(entry.Path, name); err != nil {
+			a.size, a.value = "?", fmt.Sprintf("<%v>", err)
+		} else {
+			a.size = strconv.Itoa(len(value))
+			switch r.opts.XattrValues {
+			case "hex":
+				a.value = hex.EncodeToString(value)
+			case "quoted":
+				a.value = quoting.Quote(string(value), quoting.C, false)
+			}
+		}
+		nameWidth, sizeWidth = max(nameWidth, len(a.name)), max(sizeWidth, len(a.size))
+		attrs = append(attrs, a)
+	}
+
+	for _, a := range attrs {
+		r.indent()
+		line := fmt.Sprintf("    %-*s %*s", nameWidth, a.name, sizeWidth, a.size)
+		if a.value != "" {
+			line += " " + a.value
+		}
+		fmt.Fprintln(r.w, line)
+	}
+}
+
+// quote returns a name or path quoted in the style of the renderer
+func (r *Renderer) quote(name string) string {
+	return quoting.Quote(name, r.quoting, r.hideControl)
+}
+
+// linkTarget returns the quoted target of a symlink entry, or a marker when
+// the target could not be read. It is empty for other entries.
+func (r *Renderer) linkTarget(entry Entry) string {
+	if entry.Info.Mode()&os.ModeSymlink == 0 {
+		return ""
+	}
+	if entry.LinkTarget == "" {
+		return "<unresolved>"
+	}
+	return r.quote(entry.LinkTarget)
+}
+
+// measureNames widens the name column to fit the quoted names of the
+// entries, and notes whether any of them starts with a quote
+func (r *Renderer) measureNames(entries []Entry, maxLengths map[string]int) {
+	for _, entry := range entries {
+		name := r.quote(entry.Info.Name())
+		if width := icons.Width(name); width > maxLengths["fileName"] {
+			maxLengths["fileName"] = width
+		}
+		if r.quoting.NeedsQuotes() && startsWithQuote(name) {
+			maxLengths["quoted"] = 1
+		}
+	}
+}
+
+// alignQuotes returns the space put before a name without quotes when
+// others in the listing have them, so the names line up as in GNU ls
+func (r *Renderer) alignQuotes(entry Entry, maxLengths map[string]int) string {
+	if maxLengths["quoted"] == 0 || startsWithQuote(r.quote(entry.Info.Name())) {
+		return ""
+	}
+	return " "
+}
+
+// startsWithQuote reports whether a quoted name opens with a quote
+func startsWithQuote(name string) bool {
+	return strings.HasPrefix(name, "'") || strings.HasPrefix(name, "\"")
+}
+
+// measureContexts widens the security context column to fit the entries
+// in short format, where it is right aligned before the names as in GNU ls.
+// Long format measures it with the other fields.
+func (r *Renderer) measureContexts(entries []Entry, maxLengths map[string]int) {
+	if !r.opts.Context {
+		return
+	}
+	for _, entry := range entries {
+		if context := labelsOf(entry.Path, entry.Info).context; len(context) > maxLengths["context"] {
+			maxLengths["context"] = len(context)
+		}
+	}
+}
+
+// measureIcons widens the icon column to fit the icons of the entries
+func (r *Renderer) measureIcons(entries []Entry, maxLengths map[string]int) {
+	if r.Icons == nil {
+		return
+	}
+	for _, entry := range entries {
+		if width := icons.Width(r.Icons.Icon(entry.Info)); width > maxLengths["icon"] {
+			maxLengths["icon"] = width
+		}
+	}
+}
+
+// iconPrefix returns the icon of a file padded to the width of the icon
+// column and followed by a space, so names stay aligned whatever the width
+// of each icon. It is empty when icons are off.
+func (r *Renderer) iconPrefix(file os.FileInfo, maxLengths map[string]int) string {
+	if r.Icons == nil {
+		return ""
+	}
+	icon := r.Icons.Icon(file)
+	return icon + strings.Repeat(" ", maxLengths["icon"]-icons.Width(icon)+1)
+}
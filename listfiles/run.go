@@ -0,0 +1,290 @@
+package listfiles
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go-ls-commands/archivefs"
+	"go-ls-commands/gitrev"
+	"go-ls-commands/icons"
+	"go-ls-commands/sorting"
+)
+
+// Run lists the paths named on the command line the way ls does, writing
+// to w. Paths that cannot be listed are reported in argument order first,
+// then the files among the paths are listed, then the contents of each
+// directory, followed by the totals and reports the options ask for. With
+// no paths the current directory is listed. Walks and size calculations
+// stop once ctx is cancelled. Errors that prevent listing anything at all
+// are returned.
+func Run(ctx context.Context, w io.Writer, paths []string, opts Options) error {
+	// Paths can be read from a file instead, and then there are no others
+	var names *emptyNames
+	if opts.Files0From != "" {
+		if len(paths) > 0 {
+			return fmt.Errorf("extra operand '%s'\nfile operands cannot be combined with --files0-from", paths[0])
+		}
+		var nameErrs []error
+		var err error
+		if paths, nameErrs, err = openFiles0(opts.Files0From); err != nil {
+			return err
+		}
+		names = &emptyNames{nameErrs}
+	} else if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	if opts.Glob {
+		paths = ExpandGlobs(paths)
+	}
+
+	// A git revision is listed through a single lister for all paths, and
+	// so are the operating system's files outside archives
+	var revLister *Lister
+	if opts.GitRev != "" {
+		var err error
+		if revLister, err = gitRevLister(opts); err != nil {
+			return err
+		}
+		revLister = revLister.WithContext(ctx)
+	}
+	osLister := NewLister(opts).WithContext(ctx)
+
+	renderer := NewRenderer(w, opts)
+	renderer.ShowHeaders = len(paths) > 1 || opts.Recursive
+	if renderer.Icons != nil {
+		// Icons can be overridden from a config file
+		table, err := icons.Load()
+		if err != nil {
+			renderer.RenderError(err)
+		}
+		renderer.Icons = table
+	}
+
+	if opts.Tree {
+		renderTrees(ctx, renderer, revLister, osLister, paths, names, opts)
+		return nil
+	}
+
+	// Paths are checked in argument order, so that missing ones are
+	// reported in that order before anything is listed
+	listed := map[string][]pathListing{}
+	for _, path := range paths {
+		var listing pathListing
+		if path == "" && names != nil {
+			renderer.RenderError(names.next())
+			listed[path] = append(listed[path], listing)
+			continue
+		}
+		if listing.lister = revLister; listing.lister == nil {
+			var err error
+			if listing.lister, err = listerFor(ctx, path, opts, osLister); err != nil {
+				renderer.RenderError(err)
+				listed[path] = append(listed[path], listing)
+				continue
+			}
+		}
+
+		var errs []error
+		listing.files, listing.dirs, errs = listing.lister.Args([]string{path})
+		for _, err := range errs {
+			renderer.RenderError(err)
+		}
+		listing.ok = len(errs) == 0
+		listed[path] = append(listed[path], listing)
+	}
+
+	sorting.SortFiles(paths)
+	var files []Entry
+	var dirs, totals []dirArg
+	for _, path := range paths {
+		listing := listed[path][0]
+		listed[path] = listed[path][1:]
+		if listing.lister == nil {
+			continue
+		}
+
+		if listing.ok {
+			totals = append(totals, dirArg{listing.lister, path})
+		}
+		files = append(files, listing.files...)
+		for _, dir := range listing.dirs {
+			dirs = append(dirs, dirArg{listing.lister, dir})
+		}
+	}
+
+	// Files are listed first, then the contents of each directory
+	renderer.RenderFiles(files)
+	for _, dir := range dirs {
+		if err := dir.lister.Walk(dir.path, renderer.RenderDirectory); err != nil {
+			renderer.RenderError(err)
+			return nil
+		}
+	}
+
+	// The grand total adds up everything named on the command line
+	if opts.Total {
+		total, err := grandTotal(totals)
+		if err != nil && ctx.Err() != nil {
+			renderer.RenderError(err)
+			return nil
+		}
+		renderer.RenderGrandTotal(total)
+	}
+	renderer.RenderAudit()
+	renderer.RenderDired()
+	return nil
+}
+
+// dirArg is a directory named on the command line with the lister for it
+type dirArg struct {
+	lister *Lister
+	path   string
+}
+
+// grandTotal adds up the sizes of the paths for --total. The paths of each
+// lister are measured together, so that a file hard linked under two of
+// them counts once, as with du. Errors other than cancellation only leave
+// out what could not be read; the first is returned.
+func grandTotal(args []dirArg) (int64, error) {
+	var listers []*Lister
+	paths := map[*Lister][]string{}
+	for _, arg := range args {
+		if _, ok := paths[arg.lister]; !ok {
+			listers = append(listers, arg.lister)
+		}
+		paths[arg.lister] = append(paths[arg.lister], arg.path)
+	}
+
+	var total int64
+	var firstErr error
+	for _, lister := range listers {
+		size, err := lister.TotalSize(paths[lister]...)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		total += size
+	}
+	return total, firstErr
+}
+
+// pathListing is what a path named on the command line holds, found
+// before the paths are sorted
+type pathListing struct {
+	lister *Lister
+	files  []Entry
+	dirs   []string
+	ok     bool
+}
+
+// openFiles0 reads the paths to list from the file named by --files0-from,
+// or from standard input for -
+func openFiles0(name string) ([]string, []error, error) {
+	if name == "-" {
+		return ReadFiles0(os.Stdin, name)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open '%s' for reading: %v", name, err)
+	}
+	defer f.Close()
+	return ReadFiles0(f, name)
+}
+
+// emptyNames holds the errors for the empty names read by --files0-from,
+// which are reported in their place among the missing paths
+type emptyNames struct {
+	errs []error
+}
+
+// next returns the error for the next empty name
+func (n *emptyNames) next() error {
+	err := n.errs[0]
+	n.errs = n.errs[1:]
+	return err
+}
+
+// renderTrees prints the hierarchy below each path as a tree, followed by
+// the number of directories and files shown
+func renderTrees(ctx context.Context, renderer *Renderer, revLister, osLister *Lister, paths []string, names *emptyNames, opts Options) {
+	// Missing paths are reported first, in argument order, the way other
+	// listings do
+	var found []string
+	listers := map[string]*Lister{}
+	for _, path := range paths {
+		if path == "" && names != nil {
+			renderer.RenderError(names.next())
+			continue
+		}
+		lister := revLister
+		if lister == nil {
+			var err error
+			if lister, err = listerFor(ctx, path, opts, osLister); err != nil {
+				renderer.RenderError(err)
+				continue
+			}
+		}
+
+		if _, _, errs := lister.Args([]string{path}); len(errs) > 0 {
+			for _, err := range errs {
+				renderer.RenderError(err)
+			}
+			continue
+		}
+		found = append(found, path)
+		listers[path] = lister
+	}
+
+	sorting.SortFiles(found)
+	for _, path := range found {
+		renderer.RenderTree(listers[path].Tree(path))
+	}
+	renderer.RenderTreeSummary()
+	renderer.RenderAudit()
+}
+
+// listerFor picks the lister for a path, which browses the inside of an
+// archive when --archive is set and the path starts with one, and is
+// osLister otherwise
+func listerFor(ctx context.Context, path string, opts Options, osLister *Lister) (*Lister, error) {
+	if opts.Archive {
+		if archive, _, ok := archivefs.Split(path); ok {
+			fsys, err := archivefs.Open(archive)
+			if err != nil {
+				return nil, fmt.Errorf("cannot open archive '%s': %v", archive, err)
+			}
+			return NewFSListerAt(fsys, archive, opts).WithContext(ctx), nil
+		}
+	}
+	return osLister, nil
+}
+
+// gitRevLister creates a lister for the tree of the revision named by
+// --git-rev, in the repository holding the current directory. Paths are
+// taken relative to the current directory inside that tree.
+func gitRevLister(opts Options) (*Lister, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	root, gitDir, err := gitrev.Discover(cwd)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := gitrev.Open(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	fsys, err := repo.TreeFS(opts.GitRev)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve revision '%s': %v", opts.GitRev, err)
+	}
+
+	dir, err := filepath.Rel(root, cwd)
+	if err != nil {
+		return nil, err
+	}
+	return NewFSListerIn(fsys, root, filepath.ToSlash(dir), opts), nil
+}
@@ -0,0 +1,110 @@
+package listfiles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGrandTotalHardLinks(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a/f"), make([]byte, 10000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(dir, "a/f"), filepath.Join(dir, "b/g")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two directories and one copy of the file
+	var want int64 = 10000
+	for _, sub := range []string{"a", "b"} {
+		info, err := os.Lstat(filepath.Join(dir, sub))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += info.Size()
+	}
+
+	lister := NewLister(Options{Total: true})
+	args := []dirArg{{lister, filepath.Join(dir, "a")}, {lister, filepath.Join(dir, "b")}}
+	total, err := grandTotal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != want {
+		t.Errorf("grandTotal(a, b) = %d, want %d", total, want)
+	}
+}
+
+func TestRunTreeAuditJSON(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "open"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "open"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	// Errors about missing paths stay out of the JSON report
+	var buf bytes.Buffer
+	opts := Options{Tree: true, Audit: "json"}
+	paths := []string{dir, filepath.Join(dir, "missing")}
+	if err := Run(context.Background(), &buf, paths, opts); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var report []Finding
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Output is not JSON: %v\n%s", err, buf.String())
+	}
+	if len(report) != 1 || report[0].Reason != "world-writable file" {
+		t.Errorf("Report = %+v", report)
+	}
+}
+
+func TestRunFiles0Order(t *testing.T) {
+	dir := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	if err := os.WriteFile("a.go", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("in", []byte("a.go\x00zz\x00\x00-foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Empty names are reported in their place among the missing paths
+	var buf bytes.Buffer
+	if err := Run(context.Background(), &buf, nil, Options{OnePerLine: true, Files0From: "in"}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := "ls: cannot access 'zz': No such file or directory\n" +
+		"ls: in:3: invalid zero-length file name\n" +
+		"ls: cannot access '-foo': No such file or directory\n"
+	if !strings.HasPrefix(buf.String(), want) || !strings.Contains(buf.String(), "a.go") {
+		t.Errorf("Run() output:\n%s\nwant it to start with:\n%s", buf.String(), want)
+	}
+
+	if err := Run(context.Background(), &buf, []string{"a.go"}, Options{Files0From: "in"}); err == nil {
+		t.Errorf("Run() with paths and --files0-from expected an error")
+	}
+	if err := Run(context.Background(), &buf, nil, Options{Files0From: "missing"}); err == nil {
+		t.Errorf("Run() with a missing --files0-from file expected an error")
+	}
+}
@@ -0,0 +1,77 @@
+package listfiles
+
+import (
+	"io"
+	"os"
+	"slices"
+
+	"go-ls-commands/predicate"
+)
+
+// streamBatchSize is the number of entries read from a directory at a time
+// when listing in unsorted mode
+const streamBatchSize = 1024
+
+// streamDir delivers a directory in the order entries are returned by the
+// filesystem, passing each batch to fn as soon as it is read so that memory
+// use stays bounded no matter how large the directory is. The names of
+// subdirectories are returned for recursive listings.
+func (l *Lister) streamDir(dir string, fn func(Directory) error) ([]string, error) {
+	f, err := l.fsys.Open(dir)
+	if err != nil {
+		return nil, fn(Directory{Path: dir, Err: err})
+	}
+	defer f.Close()
+
+	var dirs []string
+	batch := Directory{Path: dir, Partial: true}
+
+	// Add . and .. if allFiles is set
+	if l.opts.AllFiles {
+		for _, info := range l.dotEntries(dir) {
+			if info.Name() == "." {
+				info = l.withDirSize(dir, info)
+			}
+			if !predicate.MatchAll(l.opts.Predicates, l.fsys, joinPath(dir, info.Name()), info) {
+				continue
+			}
+			batch.Entries = append(batch.Entries, l.newEntry(joinPath(dir, info.Name()), info))
+		}
+	}
+
+	for {
+		files, err := readDir(f, streamBatchSize)
+
+		// Skip hidden and ignored files
+		files = slices.DeleteFunc(files, func(file os.FileInfo) bool { return l.isExcluded(dir, file) })
+		l.applyDirSizes(dir, files)
+
+		for _, file := range files {
+			if l.isRecursionCandidate(dir, file) {
+				dirs = append(dirs, file.Name())
+			}
+			if !predicate.MatchAll(l.opts.Predicates, l.fsys, joinPath(dir, file.Name()), file) {
+				continue // skip files not selected by the find-style filters
+			}
+			batch.Entries = append(batch.Entries, l.newEntry(joinPath(dir, file.Name()), file))
+		}
+
+		if err != nil {
+			// The last batch carries any read error and ends the directory
+			if err != io.EOF {
+				batch.Err = err
+			}
+			batch.Partial = false
+			return dirs, fn(batch)
+		}
+
+		// Entries are held until a full batch is read, so that a directory
+		// that fits in one is delivered whole
+		if len(batch.Entries) >= streamBatchSize {
+			if err := fn(batch); err != nil {
+				return dirs, err
+			}
+			batch = Directory{Path: dir, Partial: true, Continued: true}
+		}
+	}
+}
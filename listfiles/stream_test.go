@@ -0,0 +1,99 @@
+package listfiles
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestStreamDir checks that unsorted streaming visits every entry across batches
+func TestStreamDir(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Create more files than fit in a single batch
+	for i := 0; i < streamBatchSize+10; i++ {
+		_ = os.WriteFile(fmt.Sprintf("%s/file%d", tmpDir, i), nil, 0644)
+	}
+	_ = os.Mkdir(tmpDir+"/sub1", 0755)
+	_ = os.Mkdir(tmpDir+"/sub2", 0755)
+	_ = os.Mkdir(tmpDir+"/.hiddendir", 0755)
+
+	var batches []Directory
+	collect := func(dir Directory) error {
+		batches = append(batches, dir)
+		return nil
+	}
+
+	lister := NewLister(Options{Unsorted: true})
+	dirs, err := lister.streamDir(tmpDir, collect)
+	if err != nil {
+		t.Fatalf("streamDir() error = %v", err)
+	}
+	sort.Strings(dirs)
+
+	if len(dirs) != 2 || dirs[0] != "sub1" || dirs[1] != "sub2" {
+		t.Errorf("streamDir() dirs = %v, want [sub1 sub2]", dirs)
+	}
+
+	// Every entry is delivered once, in more than one batch
+	total := 0
+	seen := make(map[string]bool)
+	for i, batch := range batches {
+		total += len(batch.Entries)
+		for _, entry := range batch.Entries {
+			if seen[entry.Name] {
+				t.Errorf("Entry %s delivered twice", entry.Name)
+			}
+			seen[entry.Name] = true
+		}
+		if batch.Continued != (i > 0) || batch.Partial != (i < len(batches)-1) {
+			t.Errorf("Batch %d has Continued=%v Partial=%v", i, batch.Continued, batch.Partial)
+		}
+	}
+	if len(batches) < 2 || total != streamBatchSize+12 {
+		t.Errorf("streamDir() delivered %d entries in %d batches", total, len(batches))
+	}
+
+	lister = NewLister(Options{Unsorted: true, AllFiles: true})
+	dirs, _ = lister.streamDir(tmpDir, collect)
+	if len(dirs) != 3 {
+		t.Errorf("streamDir() with -a returned %d dirs, want 3", len(dirs))
+	}
+}
+
+// TestRenderStreamedTotal checks that a long listing streamed in several
+// batches ends with the total of all of them
+func TestRenderStreamedTotal(t *testing.T) {
+	tmpDir := t.TempDir()
+	for i := 0; i < streamBatchSize+10; i++ {
+		_ = os.WriteFile(fmt.Sprintf("%s/file%d", tmpDir, i), []byte("x"), 0644)
+	}
+
+	opts := Options{Unsorted: true, LongFormat: true}
+	var buf bytes.Buffer
+	renderer := NewRenderer(&buf, opts)
+	if err := NewLister(opts).Walk(tmpDir, renderer.RenderDirectory); err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+
+	var sorted bytes.Buffer
+	sortedOpts := Options{LongFormat: true}
+	_ = NewLister(sortedOpts).Walk(tmpDir, NewRenderer(&sorted, sortedOpts).RenderDirectory)
+	want, _, _ := strings.Cut(sorted.String(), "\n")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != streamBatchSize+11 {
+		t.Fatalf("Streamed listing has %d lines, want %d", len(lines), streamBatchSize+11)
+	}
+	for _, line := range lines[:len(lines)-1] {
+		if strings.HasPrefix(line, "total ") {
+			t.Errorf("Total line %q printed before the last batch", line)
+		}
+	}
+	if got := lines[len(lines)-1]; got != want {
+		t.Errorf("Streamed listing ends with %q, want %q", got, want)
+	}
+}
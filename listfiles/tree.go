@@ -0,0 +1,164 @@
+package listfiles
+
+import (
+	"fmt"
+)
+
+// treeConnectors are the pieces drawn in front of names in a tree listing:
+// a middle entry, the last entry, and the indents below each of them
+type treeConnectors struct {
+	entry, last, pipe, blank string
+}
+
+var (
+	utf8Connectors  = treeConnectors{"├── ", "└── ", "│   ", "    "}
+	asciiConnectors = treeConnectors{"|-- ", "`-- ", "|   ", "    "}
+)
+
+// TreeNode is an entry of a tree listing along with the entries below it
+type TreeNode struct {
+	Entry
+	Children []*TreeNode
+	Err      error
+}
+
+// Tree lists the hierarchy below path, descending no further than the
+// depth limit of the options. Entries are sorted and filtered as in any
+// other listing, except that directories are kept even when find-style
+// filters reject them so that the files below them stay in place.
+// Symlinks to directories are shown but never followed.
+func (l *Lister) Tree(path string) *TreeNode {
+	info, err := l.stat(path)
+	if err != nil {
+		return &TreeNode{Entry: Entry{Name: path, Path: path}, Err: err}
+	}
+
+	root := &TreeNode{Entry: l.newEntry(path, CustomFileInfo{info, path})}
+	if info.IsDir() {
+		l.treeChildren(root, 1)
+	}
+	return root
+}
+
+// treeChildren reads the entries of a directory node at the given depth
+func (l *Lister) treeChildren(node *TreeNode, depth int) {
+	dir, subdirs := l.serveDir(node.Path)
+	if dir.Err != nil {
+		node.Err = dir.Err
+		return
+	}
+
+	descend := make(map[string]bool, len(subdirs))
+	for _, name := range subdirs {
+		descend[name] = true
+	}
+
+	for _, entry := range dir.Entries {
+		if entry.Name == "." || entry.Name == ".." {
+			continue
+		}
+
+		child := &TreeNode{Entry: entry}
+		node.Children = append(node.Children, child)
+		if entry.Info.IsDir() && descend[entry.Name] && (l.opts.TreeDepth == 0 || depth < l.opts.TreeDepth) {
+			l.treeChildren(child, depth+1)
+		}
+	}
+}
+
+// RenderTree prints a tree listing, with the long format fields of each
+// entry as a column in front of the connectors when listing in long format
+func (r *Renderer) RenderTree(root *TreeNode) {
+	r.wrote = true
+
+	metadata := r.newMetadata()
+	if root.Info != nil {
+		r.measureIcons([]Entry{root.Entry}, metadata.MaxFieldLengths)
+		treeIconWidths(r, root, metadata.MaxFieldLengths)
+	}
+	if r.opts.LongFormat && root.Info != nil {
+		updateFieldLengths(root.Path, root.Info, r.opts, metadata.MaxFieldLengths)
+		treeFieldLengths(root, r.opts, metadata.MaxFieldLengths)
+	}
+
+	connectors := utf8Connectors
+	if r.opts.ASCIITree {
+		connectors = asciiConnectors
+	}
+
+	r.printTreeLine(root.Path, root, "", metadata)
+	r.printTreeChildren(root, "", connectors, metadata)
+}
+
+// RenderTreeSummary prints the number of directories and files shown in
+// the tree listings rendered so far
+func (r *Renderer) RenderTreeSummary() {
+	fmt.Fprintf(r.w, "\n%s, %s\n", plural(r.treeDirs, "directory", "directories"), plural(r.treeFiles, "file", "files"))
+}
+
+// treeFieldLengths measures the long format fields of every entry below node
+func treeFieldLengths(node *TreeNode, opts Options, maxLengths map[string]int) {
+	for _, child := range node.Children {
+		updateFieldLengths(node.Path, child.Info, opts, maxLengths)
+		treeFieldLengths(child, opts, maxLengths)
+	}
+}
+
+// treeIconWidths measures the icons of every entry below node
+func treeIconWidths(r *Renderer, node *TreeNode, maxLengths map[string]int) {
+	for _, child := range node.Children {
+		r.measureIcons([]Entry{child.Entry}, maxLengths)
+		treeIconWidths(r, child, maxLengths)
+	}
+}
+
+// printTreeChildren prints the entries below a node, indented by prefix
+func (r *Renderer) printTreeChildren(node *TreeNode, prefix string, connectors treeConnectors, metadata FileMetadata) {
+	for i, child := range node.Children {
+		connector, indent := connectors.entry, connectors.pipe
+		if i == len(node.Children)-1 {
+			connector, indent = connectors.last, connectors.blank
+		}
+
+		if child.Info.IsDir() {
+			r.treeDirs++
+		} else {
+			r.treeFiles++
+		}
+
+		r.printTreeLine(node.Path, child, prefix+connector, metadata)
+		r.printTreeChildren(child, prefix+indent, connectors, metadata)
+	}
+}
+
+// printTreeLine prints a single entry of a tree listing
+func (r *Renderer) printTreeLine(dir string, node *TreeNode, connector string, metadata FileMetadata) {
+	if node.Info == nil {
+		fmt.Fprintf(r.w, "%s [%v]\n", r.quote(node.Name), node.Err)
+		return
+	}
+
+	if r.opts.LongFormat {
+		fmt.Fprint(r.w, longFields(dir, node.Info, r.opts, metadata.MaxFieldLengths))
+	}
+	if node.GitStatus != "" {
+		fmt.Fprint(r.w, node.GitStatus+" ")
+	}
+	fmt.Fprintf(r.w, "%s%s%s", connector, r.iconPrefix(node.Info, metadata.MaxFieldLengths), r.displayName(node.Entry))
+
+	if target := r.linkTarget(node.Entry); target != "" {
+		fmt.Fprintf(r.w, " -> %s", target)
+	}
+	if node.Err != nil {
+		fmt.Fprintf(r.w, " [error opening dir]")
+	}
+	fmt.Fprintln(r.w, r.auditNote(node.Entry))
+}
+
+// plural formats a count with the singular or plural form of a noun
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, pluralForm)
+}
@@ -0,0 +1,163 @@
+package listfiles
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go-ls-commands/predicate"
+)
+
+// renderTree renders the tree below "." of the test file system, without
+// color codes to keep expectations readable
+func renderTree(t *testing.T, opts Options) string {
+	t.Helper()
+	opts.Tree = true
+	lister := NewFSLister(testMapFS(), opts)
+
+	var out bytes.Buffer
+	renderer := NewRenderer(&out, opts)
+	renderer.RenderTree(lister.Tree("."))
+	renderer.RenderTreeSummary()
+	return strings.NewReplacer("\033[0m", "", "\033[32m", "", "\033[34m", "").Replace(out.String())
+}
+
+func TestRenderTree(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"utf-8", Options{}, `.
+├── cmd
+│   └── tool
+│       └── tool.go
+├── docs
+│   └── guide.txt
+├── main.go
+└── README.md
+
+3 directories, 4 files
+`},
+		{"ascii with hidden files", Options{ASCIITree: true, AllFiles: true}, `.
+|-- cmd
+|   ` + "`" + `-- tool
+|       ` + "`" + `-- tool.go
+|-- docs
+|   ` + "`" + `-- guide.txt
+|-- .hidden
+|-- main.go
+` + "`" + `-- README.md
+
+3 directories, 5 files
+`},
+		{"depth limit", Options{TreeDepth: 1, ReverseSort: true}, `.
+├── README.md
+├── main.go
+├── docs
+└── cmd
+
+2 directories, 2 files
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderTree(t, tt.opts); got != tt.want {
+				t.Errorf("RenderTree() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTreeKeepsFilteredDirectories(t *testing.T) {
+	p, err := predicate.Parse("name", "*.go")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := `.
+├── cmd
+│   └── tool
+│       └── tool.go
+├── docs
+└── main.go
+
+3 directories, 2 files
+`
+	if got := renderTree(t, Options{Predicates: []predicate.Predicate{p}}); got != want {
+		t.Errorf("RenderTree() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderTreeFiltersSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"d/f.go", "x.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("d", filepath.Join(dir, "ld")); err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(value string) string {
+		p, err := predicate.Parse("type", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := Options{Tree: true, Predicates: []predicate.Predicate{p}}
+		var out bytes.Buffer
+		renderer := NewRenderer(&out, opts)
+		renderer.RenderTree(NewLister(opts).Tree(dir))
+		renderer.RenderTreeSummary()
+		return strings.NewReplacer("\033[0m", "", "\033[34m", "", "\033[01;36m", "").Replace(out.String())
+	}
+
+	want := dir + `
+├── d
+│   └── f.go
+└── x.txt
+
+1 directory, 2 files
+`
+	if got := render("f"); got != want {
+		t.Errorf("RenderTree() with --type=f =\n%s\nwant\n%s", got, want)
+	}
+
+	want = dir + `
+├── d
+└── ld -> d
+
+1 directory, 1 file
+`
+	if got := render("l"); got != want {
+		t.Errorf("RenderTree() with --type=l =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderTreeLongFormat(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"src":         {Mode: 0755 | 1<<31, ModTime: modTime},
+		"src/main.go": {Data: []byte("package main"), Mode: 0644, ModTime: modTime},
+	}
+	opts := Options{Tree: true, LongFormat: true}
+
+	var out bytes.Buffer
+	renderer := NewRenderer(&out, opts)
+	renderer.RenderTree(NewFSLister(fsys, opts).Tree("src"))
+
+	want := "drwxr-xr-x ? ? ?  0 Mar  1 10:30 \033[34msrc\033[0m\n" +
+		"-rw-r--r-- ? ? ? 12 Mar  1 10:30 └── \033[0mmain.go\033[0m\n"
+	if out.String() != want {
+		t.Errorf("RenderTree() =\n%q\nwant\n%q", out.String(), want)
+	}
+}
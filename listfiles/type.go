@@ -10,11 +10,28 @@ type CustomFileInfo struct {
 	name string
 }
 
+// NewCustomFileInfo wraps a FileInfo so that it reports the given name
+func NewCustomFileInfo(info os.FileInfo, name string) CustomFileInfo {
+	return CustomFileInfo{info, name}
+}
+
 // Name overrides the original FileInfo's Name method
 func (f CustomFileInfo) Name() string {
 	return f.name
 }
 
+// sizedFileInfo wraps os.FileInfo to override the Size() method, as done
+// for directories whose size is that of everything below them
+type sizedFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+// Size overrides the original FileInfo's Size method
+func (f sizedFileInfo) Size() int64 {
+	return f.size
+}
+
 // FileMetadata holds the maximum field lengths for formatting
 type FileMetadata struct {
 	MaxFieldLengths map[string]int
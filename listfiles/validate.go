@@ -2,7 +2,13 @@ package listfiles
 
 import (
 	"fmt"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
+
+	"go-ls-commands/predicate"
+	"go-ls-commands/quoting"
 )
 
 // Options struct to hold all command flags
@@ -11,7 +17,101 @@ type Options struct {
 	AllFiles      bool
 	Recursive     bool
 	SortByTime    bool
+	SortBySize    bool
 	ReverseSort   bool
+	Unsorted      bool
+	OnePerLine    bool
+	AlmostAll     bool
+	DirectoryOnly bool
+	IgnoreBackups bool
+	GitIgnore     bool
+	Archive       bool
+	Tree          bool
+	ASCIITree     bool
+	DirSize       bool
+	Total         bool
+	GitStatus     bool
+
+	// ShowACL prints the ACL entries of files that have any under their
+	// line in long format
+	ShowACL bool
+
+	// Xattrs lists the extended attributes of each file and the size of
+	// their values under its line in long format
+	Xattrs bool
+
+	// XattrValues also dumps attribute values with --xattrs: hex, quoted,
+	// or empty for sizes alone
+	XattrValues string
+
+	// Caps and Attrs add columns of file capabilities and inode flags to
+	// the long format
+	Caps  bool
+	Attrs bool
+
+	// BlockSize is the unit sizes and the total of a long listing count
+	// in, or 0 for bytes and a total in 1024 byte blocks. BlockSizeSuffix
+	// follows each count when the unit was given as a bare suffix, such as
+	// K or MB.
+	BlockSize       int64
+	BlockSizeSuffix string
+
+	// PermFormat is how long format shows permissions: octal, both, or
+	// empty for symbolic
+	PermFormat string
+
+	// Audit reports security concerns about files: annotate puts them next
+	// to each entry, text and json print a report at the end
+	Audit string
+
+	// Context shows the SELinux security context of each file
+	Context bool
+
+	// Dired adds the byte offsets of names after a long listing, for Emacs
+	Dired bool
+
+	// AllocatedSize makes --dir-size add up allocated rather than apparent sizes
+	AllocatedSize bool
+
+	// Hyperlink is when names link to their files: always, auto (only on a
+	// terminal) or never, the default
+	Hyperlink string
+
+	// Icons is when names are preceded by icons: always, auto (only on a
+	// terminal) or never, the default
+	Icons string
+
+	// QuotingStyle is how names are quoted, one of quoting.Names, or empty
+	// for shell-escape on a terminal and literal otherwise
+	QuotingStyle string
+
+	// HideControlChars shows unprintable characters in names as ?, for the
+	// styles that do not escape them
+	HideControlChars bool
+
+	// Glob expands wildcards in path arguments, for patterns quoted from
+	// the shell
+	Glob bool
+
+	// Files0From names a file, or - for standard input, holding the paths
+	// to list separated by NUL characters
+	Files0From string
+
+	// TreeDepth limits how deep a tree listing descends, or is 0 for no limit
+	TreeDepth int
+
+	// GitRev names a git revision whose tree is listed instead of the
+	// working directory
+	GitRev string
+
+	IgnorePatterns []string
+	HidePatterns   []string
+	Predicates     []predicate.Predicate
+}
+
+// ShowHidden reports whether entries starting with a dot should be listed
+func (o Options) ShowHidden() bool {
+	return o.AllFiles || o.AlmostAll
 }
 
 func ValidateFlags(args []string) (Options, error) {
@@ -24,6 +124,15 @@ func ValidateFlags(args []string) (Options, error) {
 			if strings.HasPrefix(flagStr, "-") {
 				// Handle long flags (--long)
 				flagStr = strings.TrimPrefix(flagStr, "-")
+
+				// Handle long flags with a value (--ignore=PATTERN)
+				if name, value, ok := strings.Cut(flagStr, "="); ok {
+					if err := parseValueFlag(&opts, name, value); err != nil {
+						return Options{}, err
+					}
+					continue
+				}
+
 				switch flagStr {
 				case "long":
 					opts.LongFormat = true
@@ -35,12 +144,63 @@ func ValidateFlags(args []string) (Options, error) {
 					opts.SortByTime = true
 				case "reverse":
 					opts.ReverseSort = true
+				case "almost-all":
+					opts.AlmostAll = true
+				case "directory":
+					opts.DirectoryOnly = true
+				case "ignore-backups":
+					opts.IgnoreBackups = true
+				case "gitignore":
+					opts.GitIgnore = true
+				case "archive":
+					opts.Archive = true
+				case "glob":
+					opts.Glob = true
+				case "tree":
+					opts.Tree = true
+				case "dir-size":
+					opts.DirSize = true
+				case "total":
+					opts.Total = true
+				case "git":
+					opts.GitStatus = true
+				case "hyperlink":
+					opts.Hyperlink = "always"
+				case "icons":
+					opts.Icons = "always"
+				case "xattrs":
+					opts.Xattrs = true
+				case "audit":
+					opts.Audit = "annotate"
+				case "caps":
+					opts.Caps = true
+				case "attrs":
+					opts.Attrs = true
+				case "context":
+					opts.Context = true
+				case "acl":
+					opts.ShowACL = true
+				case "dired":
+					opts.Dired = true
+				case "hide-control-chars":
+					opts.HideControlChars = true
+				case "show-control-chars":
+					opts.HideControlChars = false
+				case "escape":
+					opts.QuotingStyle = "escape"
+				case "literal":
+					opts.QuotingStyle = "literal"
+				case "empty":
+					if err := parseValueFlag(&opts, "empty", ""); err != nil {
+						return Options{}, err
+					}
 				default:
 					return Options{}, fmt.Errorf("invalid option --%s", flagStr)
 				}
 			} else {
 				// Handle short flags (-l)
-				for _, flag := range flagStr {
+			shortFlags:
+				for i, flag := range flagStr {
 					switch flag {
 					case 'l':
 						opts.LongFormat = true
@@ -50,8 +210,38 @@ func ValidateFlags(args []string) (Options, error) {
 						opts.Recursive = true
 					case 't':
 						opts.SortByTime = true
+					case 'S':
+						opts.SortBySize = true
 					case 'r':
 						opts.ReverseSort = true
+					case 'U':
+						opts.Unsorted = true
+					case '1':
+						opts.OnePerLine = true
+					case 'A':
+						opts.AlmostAll = true
+					case 'd':
+						opts.DirectoryOnly = true
+					case 'B':
+						opts.IgnoreBackups = true
+					case 'Z':
+						opts.Context = true
+					case '@':
+						opts.Xattrs = true
+					case 'D':
+						opts.Dired = true
+					case 'q':
+						opts.HideControlChars = true
+					case 'b':
+						opts.QuotingStyle = "escape"
+					case 'N':
+						opts.QuotingStyle = "literal"
+					case 'I':
+						// The rest of the argument is the pattern (-IPATTERN)
+						if err := parseValueFlag(&opts, "ignore", flagStr[i+1:]); err != nil {
+							return Options{}, err
+						}
+						break shortFlags
 					default:
 						return Options{}, fmt.Errorf("invalid option -- '%c'", flag)
 					}
@@ -60,5 +250,189 @@ func ValidateFlags(args []string) (Options, error) {
 		}
 	}
 
+	// --dired implies long format, and its offsets are for Emacs, which
+	// follows no links
+	if opts.Dired {
+		opts.LongFormat = true
+		opts.Hyperlink = ""
+	}
+
 	return opts, nil
-}
\ No newline at end of file
+}
+
+// parseValueFlag handles a long flag of the form --name=value
+func parseValueFlag(opts *Options, name, value string) error {
+	switch name {
+	case "sort":
+		switch value {
+		case "none":
+			opts.Unsorted = true
+		case "time":
+			opts.SortByTime = true
+		case "size":
+			opts.SortBySize = true
+		default:
+			return fmt.Errorf("invalid argument '%s' for '--sort'", value)
+		}
+	case "dir-size":
+		switch value {
+		case "apparent":
+			opts.AllocatedSize = false
+		case "allocated":
+			opts.AllocatedSize = true
+		default:
+			return fmt.Errorf("invalid argument '%s' for '--dir-size'", value)
+		}
+		opts.DirSize = true
+	case "ignore", "hide":
+		if value == "" {
+			return fmt.Errorf("option '--%s' requires a pattern", name)
+		}
+		if _, err := path.Match(value, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s' for '--%s'", value, name)
+		}
+		if name == "ignore" {
+			opts.IgnorePatterns = append(opts.IgnorePatterns, value)
+		} else {
+			opts.HidePatterns = append(opts.HidePatterns, value)
+		}
+	case "charset":
+		switch value {
+		case "ascii":
+			opts.ASCIITree = true
+		case "utf-8", "utf8", "UTF-8":
+			opts.ASCIITree = false
+		default:
+			return fmt.Errorf("invalid argument '%s' for '--charset'", value)
+		}
+	case "hyperlink", "icons":
+		if value != "always" && value != "auto" && value != "never" {
+			return fmt.Errorf("invalid argument '%s' for '--%s'", value, name)
+		}
+		if name == "hyperlink" {
+			opts.Hyperlink = value
+		} else {
+			opts.Icons = value
+		}
+	case "xattrs":
+		switch value {
+		case "size":
+			opts.XattrValues = ""
+		case "hex", "quoted":
+			opts.XattrValues = value
+		default:
+			return fmt.Errorf("invalid argument '%s' for '--xattrs'", value)
+		}
+		opts.Xattrs = true
+	case "block-size":
+		size, suffix, err := parseBlockSize(value)
+		if err != nil {
+			return err
+		}
+		opts.BlockSize, opts.BlockSizeSuffix = size, suffix
+	case "perm-format":
+		switch value {
+		case "symbolic":
+			opts.PermFormat = ""
+		case "octal", "both":
+			opts.PermFormat = value
+		default:
+			return fmt.Errorf("invalid argument '%s' for '--perm-format'", value)
+		}
+	case "audit":
+		if value != "annotate" && value != "text" && value != "json" {
+			return fmt.Errorf("invalid argument '%s' for '--audit'", value)
+		}
+		opts.Audit = value
+	case "quoting-style":
+		if _, err := quoting.ParseStyle(value); err != nil {
+			return fmt.Errorf("invalid argument '%s' for '--quoting-style'", value)
+		}
+		opts.QuotingStyle = value
+	case "level":
+		depth, err := strconv.Atoi(value)
+		if err != nil || depth < 1 {
+			return fmt.Errorf("invalid level '%s' for '--level'", value)
+		}
+		opts.TreeDepth = depth
+	case "files0-from":
+		if value == "" {
+			return fmt.Errorf("option '--files0-from' requires a file")
+		}
+		opts.Files0From = value
+	case "git-rev":
+		if value == "" {
+			return fmt.Errorf("option '--git-rev' requires a revision")
+		}
+		opts.GitRev = value
+	default:
+		// Find-style filters are parsed by the predicate package
+		if !slices.Contains(predicate.Names, name) {
+			return fmt.Errorf("invalid option --%s", name)
+		}
+		p, err := predicate.Parse(name, value)
+		if err != nil {
+			return err
+		}
+		opts.Predicates = append(opts.Predicates, p)
+	}
+	return nil
+}
+
+// blockSizeUnits holds the power of each block size suffix letter
+var blockSizeUnits = map[byte]int{'K': 1, 'M': 2, 'G': 3, 'T': 4, 'P': 5, 'E': 6}
+
+// parseBlockSize parses a --block-size value: a number of bytes, a suffix
+// such as K (1024), KiB (1024) or KB (1000) for powers of those, or both,
+// as 4K. When only a suffix is given it is returned to be shown after the
+// count, as GNU ls does.
+func parseBlockSize(value string) (int64, string, error) {
+	invalid := fmt.Errorf("invalid --block-size argument '%s'", value)
+
+	digits := strings.TrimRight(value, "KMGTPEkiB")
+	unit := value[len(digits):]
+	size := int64(1)
+	if digits != "" {
+		n, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, "", invalid
+		}
+		size = n
+	}
+	if unit == "" {
+		return size, "", nil
+	}
+
+	// The letter may be lowercase only for kilo
+	letter := unit[0]
+	if letter == 'k' {
+		letter = 'K'
+	}
+	power, ok := blockSizeUnits[letter]
+	if !ok {
+		return 0, "", invalid
+	}
+
+	base, shown := int64(1024), string(letter)
+	switch unit[1:] {
+	case "":
+	case "iB":
+		shown += "iB"
+	case "B":
+		base = 1000
+		shown = strings.Replace(shown, "K", "k", 1) + "B"
+	default:
+		return 0, "", invalid
+	}
+
+	for i := 0; i < power; i++ {
+		if size > (1<<63-1)/base {
+			return 0, "", invalid
+		}
+		size *= base
+	}
+	if digits != "" {
+		shown = ""
+	}
+	return size, shown, nil
+}
@@ -2,6 +2,7 @@ package listfiles_test
 
 import (
 	"go-ls-commands/listfiles"
+	"reflect"
 	"testing"
 )
 
@@ -17,6 +18,12 @@ func TestValidateFlags(t *testing.T) {
 		{[]string{"-R"}, false, listfiles.Options{Recursive: true}},
 		{[]string{"-t"}, false, listfiles.Options{SortByTime: true}},
 		{[]string{"-r"}, false, listfiles.Options{ReverseSort: true}},
+		{[]string{"-U"}, false, listfiles.Options{Unsorted: true}},
+		{[]string{"-1"}, false, listfiles.Options{OnePerLine: true}},
+		{[]string{"-A"}, false, listfiles.Options{AlmostAll: true}},
+		{[]string{"-d"}, false, listfiles.Options{DirectoryOnly: true}},
+		{[]string{"-B"}, false, listfiles.Options{IgnoreBackups: true}},
+		{[]string{"-I*.o"}, false, listfiles.Options{IgnorePatterns: []string{"*.o"}}},
 
 		// Single long flags
 		{[]string{"--long"}, false, listfiles.Options{LongFormat: true}},
@@ -24,11 +31,60 @@ func TestValidateFlags(t *testing.T) {
 		{[]string{"--recursive"}, false, listfiles.Options{Recursive: true}},
 		{[]string{"--time"}, false, listfiles.Options{SortByTime: true}},
 		{[]string{"--reverse"}, false, listfiles.Options{ReverseSort: true}},
+		{[]string{"--sort=none"}, false, listfiles.Options{Unsorted: true}},
+		{[]string{"--almost-all"}, false, listfiles.Options{AlmostAll: true}},
+		{[]string{"--directory"}, false, listfiles.Options{DirectoryOnly: true}},
+		{[]string{"--ignore-backups"}, false, listfiles.Options{IgnoreBackups: true}},
+		{[]string{"--gitignore"}, false, listfiles.Options{GitIgnore: true}},
+		{[]string{"--archive"}, false, listfiles.Options{Archive: true}},
+		{[]string{"--sort=time"}, false, listfiles.Options{SortByTime: true}},
+		{[]string{"--ignore=*.o"}, false, listfiles.Options{IgnorePatterns: []string{"*.o"}}},
+		{[]string{"--hide=*.o"}, false, listfiles.Options{HidePatterns: []string{"*.o"}}},
 
 		// Combined short flags
 		{[]string{"-la"}, false, listfiles.Options{LongFormat: true, AllFiles: true}},
 		{[]string{"-rt"}, false, listfiles.Options{ReverseSort: true, SortByTime: true}},
 		{[]string{"-lR"}, false, listfiles.Options{LongFormat: true, Recursive: true}},
+		{[]string{"-U1"}, false, listfiles.Options{Unsorted: true, OnePerLine: true}},
+		{[]string{"-dR"}, false, listfiles.Options{DirectoryOnly: true, Recursive: true}},
+		{[]string{"-lI*.o"}, false, listfiles.Options{LongFormat: true, IgnorePatterns: []string{"*.o"}}},
+		{[]string{"--ignore=*.o", "--ignore=*~"}, false, listfiles.Options{IgnorePatterns: []string{"*.o", "*~"}}},
+		{[]string{"--tree", "--charset=ascii", "--level=2"}, false, listfiles.Options{Tree: true, ASCIITree: true, TreeDepth: 2}},
+		{[]string{"-lS", "--dir-size", "--total"}, false, listfiles.Options{LongFormat: true, SortBySize: true, DirSize: true, Total: true}},
+		{[]string{"--dir-size=allocated", "--sort=size"}, false, listfiles.Options{DirSize: true, AllocatedSize: true, SortBySize: true}},
+		{[]string{"--git", "-l"}, false, listfiles.Options{LongFormat: true, GitStatus: true}},
+		{[]string{"--hyperlink"}, false, listfiles.Options{Hyperlink: "always"}},
+		{[]string{"--hyperlink=auto"}, false, listfiles.Options{Hyperlink: "auto"}},
+		{[]string{"--icons", "--hyperlink=never"}, false, listfiles.Options{Icons: "always", Hyperlink: "never"}},
+		{[]string{"--icons=auto"}, false, listfiles.Options{Icons: "auto"}},
+		{[]string{"-q", "--quoting-style=shell"}, false, listfiles.Options{HideControlChars: true, QuotingStyle: "shell"}},
+		{[]string{"-lb"}, false, listfiles.Options{LongFormat: true, QuotingStyle: "escape"}},
+		{[]string{"--escape", "-N"}, false, listfiles.Options{QuotingStyle: "literal"}},
+		{[]string{"--literal", "--hide-control-chars", "--show-control-chars"}, false, listfiles.Options{QuotingStyle: "literal"}},
+		{[]string{"-l", "--acl"}, false, listfiles.Options{LongFormat: true, ShowACL: true}},
+		{[]string{"-l", "--xattrs"}, false, listfiles.Options{LongFormat: true, Xattrs: true}},
+		{[]string{"-l", "-@"}, false, listfiles.Options{LongFormat: true, Xattrs: true}},
+		{[]string{"-l@"}, false, listfiles.Options{LongFormat: true, Xattrs: true}},
+		{[]string{"--xattrs=hex", "--xattrs=size"}, false, listfiles.Options{Xattrs: true}},
+		{[]string{"--xattrs=quoted"}, false, listfiles.Options{Xattrs: true, XattrValues: "quoted"}},
+		{[]string{"-l", "--caps", "--attrs"}, false, listfiles.Options{LongFormat: true, Caps: true, Attrs: true}},
+		{[]string{"-l", "--perm-format=both"}, false, listfiles.Options{LongFormat: true, PermFormat: "both"}},
+		{[]string{"--perm-format=octal", "--perm-format=symbolic"}, false, listfiles.Options{}},
+		{[]string{"-l", "--audit"}, false, listfiles.Options{LongFormat: true, Audit: "annotate"}},
+		{[]string{"--audit=json"}, false, listfiles.Options{Audit: "json"}},
+		{[]string{"-lZ"}, false, listfiles.Options{LongFormat: true, Context: true}},
+		{[]string{"--context"}, false, listfiles.Options{Context: true}},
+		{[]string{"-lD"}, false, listfiles.Options{LongFormat: true, Dired: true}},
+		{[]string{"--dired", "-l"}, false, listfiles.Options{LongFormat: true, Dired: true}},
+		{[]string{"-D"}, false, listfiles.Options{LongFormat: true, Dired: true}},
+		{[]string{"--dired", "--hyperlink=always"}, false, listfiles.Options{LongFormat: true, Dired: true}},
+		{[]string{"-l", "--block-size=K"}, false, listfiles.Options{LongFormat: true, BlockSize: 1024, BlockSizeSuffix: "K"}},
+		{[]string{"--block-size=4k"}, false, listfiles.Options{BlockSize: 4096}},
+		{[]string{"--block-size=MB"}, false, listfiles.Options{BlockSize: 1000000, BlockSizeSuffix: "MB"}},
+		{[]string{"--block-size=GiB"}, false, listfiles.Options{BlockSize: 1 << 30, BlockSizeSuffix: "GiB"}},
+		{[]string{"--block-size=512"}, false, listfiles.Options{BlockSize: 512}},
+		{[]string{"--glob", "--files0-from=-"}, false, listfiles.Options{Glob: true, Files0From: "-"}},
+		{[]string{"--git-rev=HEAD~2", "-l"}, false, listfiles.Options{LongFormat: true, GitRev: "HEAD~2"}},
 
 		// Multiple separate flags
 		{[]string{"-l", "-a"}, false, listfiles.Options{LongFormat: true, AllFiles: true}},
@@ -42,6 +98,29 @@ func TestValidateFlags(t *testing.T) {
 		{[]string{"--invalid"}, true, listfiles.Options{}},
 		{[]string{"-x"}, true, listfiles.Options{}},
 		{[]string{"-l", "-x"}, true, listfiles.Options{}},
+		{[]string{"-I"}, true, listfiles.Options{}},
+		{[]string{"--ignore="}, true, listfiles.Options{}},
+		{[]string{"--ignore=[a"}, true, listfiles.Options{}},
+		{[]string{"--files0-from="}, true, listfiles.Options{}},
+		{[]string{"--git-rev="}, true, listfiles.Options{}},
+		{[]string{"--charset=ebcdic"}, true, listfiles.Options{}},
+		{[]string{"--dir-size=blocks"}, true, listfiles.Options{}},
+		{[]string{"--hyperlink=sometimes"}, true, listfiles.Options{}},
+		{[]string{"--icons=yes"}, true, listfiles.Options{}},
+		{[]string{"--perm-format=hex"}, true, listfiles.Options{}},
+		{[]string{"--audit=xml"}, true, listfiles.Options{}},
+		{[]string{"--xattrs=base64"}, true, listfiles.Options{}},
+		{[]string{"--quoting-style=clever"}, true, listfiles.Options{}},
+		{[]string{"--block-size=0"}, true, listfiles.Options{}},
+		{[]string{"--block-size=Q"}, true, listfiles.Options{}},
+		{[]string{"--block-size=KiBB"}, true, listfiles.Options{}},
+		{[]string{"--block-size=9E"}, true, listfiles.Options{}},
+		{[]string{"--level=0"}, true, listfiles.Options{}},
+		{[]string{"--sort=bogus"}, true, listfiles.Options{}},
+		{[]string{"--bogus=1"}, true, listfiles.Options{}},
+		{[]string{"--type=x"}, true, listfiles.Options{}},
+		{[]string{"--size=big"}, true, listfiles.Options{}},
+		{[]string{"--empty=yes"}, true, listfiles.Options{}},
 		{[]string{}, false, listfiles.Options{}},
 	}
 
@@ -54,8 +133,50 @@ func TestValidateFlags(t *testing.T) {
 		}
 
 		// Compare the returned options struct with the expected struct
-		if opts != test.expected {
+		if !reflect.DeepEqual(opts, test.expected) {
 			t.Errorf("ValidateFlags(%v) = %+v, want %+v", test.args, opts, test.expected)
 		}
 	}
 }
+
+func TestShowHidden(t *testing.T) {
+	tests := []struct {
+		opts     listfiles.Options
+		expected bool
+	}{
+		{listfiles.Options{}, false},
+		{listfiles.Options{AllFiles: true}, true},
+		{listfiles.Options{AlmostAll: true}, true},
+		{listfiles.Options{AllFiles: true, AlmostAll: true}, true},
+	}
+
+	for _, test := range tests {
+		if got := test.opts.ShowHidden(); got != test.expected {
+			t.Errorf("%+v.ShowHidden() = %v, want %v", test.opts, got, test.expected)
+		}
+	}
+}
+
+func TestValidateFlagsPredicates(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{"--type=f"}, 1},
+		{[]string{"--type=f,d", "--size=+10M"}, 2},
+		{[]string{"--newer-than=2d", "--older-than=1w"}, 2},
+		{[]string{"--name=*.go", "--iname=*.GO", "--perm=/o+w", "--empty"}, 4},
+		{[]string{"-l", "--empty"}, 1},
+	}
+
+	for _, test := range tests {
+		opts, err := listfiles.ValidateFlags(test.args)
+		if err != nil {
+			t.Errorf("ValidateFlags(%v) error = %v", test.args, err)
+			continue
+		}
+		if len(opts.Predicates) != test.expected {
+			t.Errorf("ValidateFlags(%v) returned %d predicates, want %d", test.args, len(opts.Predicates), test.expected)
+		}
+	}
+}
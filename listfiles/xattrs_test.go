@@ -0,0 +1,51 @@
+package listfiles
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestXattrs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"user.comment": "two\nlines", "user.x": "\x00\x01"} {
+		if err := syscall.Setxattr(path, name, []byte(value), 0); err != nil {
+			t.Skipf("Cannot set an attribute: %v", err)
+		}
+	}
+
+	tests := []struct {
+		values string
+		want   string
+	}{
+		{"", "file\033[0m\n    user.comment 9\n    user.x       2\n"},
+		{"hex", "file\033[0m\n    user.comment 9 74776f0a6c696e6573\n    user.x       2 0001\n"},
+		{"quoted", "file\033[0m\n    user.comment 9 \"two\\nlines\"\n    user.x       2 \"\\000\\001\"\n"},
+	}
+
+	for _, tt := range tests {
+		opts := Options{LongFormat: true, Xattrs: true, XattrValues: tt.values}
+		var out bytes.Buffer
+		renderer := NewRenderer(&out, opts)
+		_ = NewLister(opts).Walk(dir, renderer.RenderDirectory)
+		if !strings.Contains(out.String(), tt.want) {
+			t.Errorf("Output with --xattrs=%s missing %q:\n%q", tt.values, tt.want, out.String())
+		}
+	}
+
+	// File systems without attributes list nothing and report no error
+	var out bytes.Buffer
+	renderer := NewRenderer(&out, Options{LongFormat: true, Xattrs: true})
+	files, _, _ := NewLister(Options{}).Args([]string{"/proc/self/status"})
+	renderer.RenderFiles(files)
+	if strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("Output for a file without attributes:\n%s", out.String())
+	}
+}
@@ -0,0 +1,327 @@
+package predicate
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Predicate decides whether a file belongs in a listing. Predicates that
+// look inside a file, such as --empty, read it at path through fsys.
+type Predicate func(fsys fs.FS, path string, info fs.FileInfo) bool
+
+// now returns the current time, replaced in tests
+var now = time.Now
+
+// Names lists the flags handled by Parse
+var Names = []string{"type", "size", "newer-than", "older-than", "name", "iname", "perm", "empty"}
+
+// MatchAll reports whether a file satisfies every predicate
+func MatchAll(predicates []Predicate, fsys fs.FS, path string, info fs.FileInfo) bool {
+	for _, p := range predicates {
+		if !p(fsys, path, info) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse builds the predicate for a --name=value flag
+func Parse(name, value string) (Predicate, error) {
+	switch name {
+	case "type":
+		return parseType(value)
+	case "size":
+		return parseSize(value)
+	case "newer-than", "older-than":
+		return parseAge(value, name == "newer-than")
+	case "name", "iname":
+		return parseName(value, name == "iname")
+	case "perm":
+		return parsePerm(value)
+	case "empty":
+		if value != "" {
+			return nil, fmt.Errorf("option '--empty' doesn't allow an argument")
+		}
+		return isEmpty, nil
+	default:
+		return nil, fmt.Errorf("unknown predicate '%s'", name)
+	}
+}
+
+// typeModes maps the letters accepted by --type to file type bits, where
+// regular files have no type bits set
+var typeModes = map[string]fs.FileMode{
+	"f": 0,
+	"d": fs.ModeDir,
+	"l": fs.ModeSymlink,
+	"p": fs.ModeNamedPipe,
+	"s": fs.ModeSocket,
+	"b": fs.ModeDevice,
+	"c": fs.ModeDevice | fs.ModeCharDevice,
+}
+
+// parseType handles --type=f,d,l with a comma separated list of types
+func parseType(value string) (Predicate, error) {
+	var wanted []fs.FileMode
+	for _, letter := range strings.Split(value, ",") {
+		mode, ok := typeModes[letter]
+		if !ok {
+			return nil, fmt.Errorf("invalid file type '%s' for '--type'", letter)
+		}
+		wanted = append(wanted, mode)
+	}
+
+	return func(_ fs.FS, path string, info fs.FileInfo) bool {
+		fileType := info.Mode().Type() &^ fs.ModeIrregular
+		for _, mode := range wanted {
+			if fileType == mode {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// sizeUnits maps size suffixes to their multiplier
+var sizeUnits = map[byte]int64{
+	'c': 1,
+	'k': 1 << 10,
+	'K': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+	'T': 1 << 40,
+}
+
+// parseSize handles --size=[+-]N[ckMGT], where + means larger than, - means
+// smaller than and no sign means exactly
+func parseSize(value string) (Predicate, error) {
+	sign, number := splitSign(value)
+	multiplier := int64(1)
+	if number != "" {
+		if unit, ok := sizeUnits[number[len(number)-1]]; ok {
+			multiplier = unit
+			number = number[:len(number)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/multiplier {
+		return nil, fmt.Errorf("invalid size '%s' for '--size'", value)
+	}
+	limit := n * multiplier
+
+	return func(_ fs.FS, path string, info fs.FileInfo) bool {
+		switch sign {
+		case '+':
+			return info.Size() > limit
+		case '-':
+			return info.Size() < limit
+		default:
+			return info.Size() == limit
+		}
+	}, nil
+}
+
+// ageUnits maps age suffixes to their duration
+var ageUnits = map[byte]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// parseAge handles --newer-than=N[smhdw] and --older-than=N[smhdw], which
+// compare the modification time against the time the flags were parsed
+func parseAge(value string, newer bool) (Predicate, error) {
+	flag := "--older-than"
+	if newer {
+		flag = "--newer-than"
+	}
+	if value == "" {
+		return nil, fmt.Errorf("invalid age '' for '%s'", flag)
+	}
+
+	unit, ok := ageUnits[value[len(value)-1]]
+	if !ok {
+		return nil, fmt.Errorf("invalid age '%s' for '%s'", value, flag)
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/int64(unit) {
+		return nil, fmt.Errorf("invalid age '%s' for '%s'", value, flag)
+	}
+	cutoff := now().Add(-time.Duration(n) * unit)
+
+	return func(_ fs.FS, path string, info fs.FileInfo) bool {
+		if newer {
+			return info.ModTime().After(cutoff)
+		}
+		return info.ModTime().Before(cutoff)
+	}, nil
+}
+
+// parseName handles --name=GLOB and the case insensitive --iname=GLOB
+func parseName(value string, ignoreCase bool) (Predicate, error) {
+	if ignoreCase {
+		value = strings.ToLower(value)
+	}
+	if _, err := path.Match(value, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s'", value)
+	}
+
+	return func(_ fs.FS, _ string, info fs.FileInfo) bool {
+		name := info.Name()
+		if ignoreCase {
+			name = strings.ToLower(name)
+		}
+		matched, _ := path.Match(value, name)
+		return matched
+	}, nil
+}
+
+// parsePerm handles --perm=MODE like find: an exact MODE, -MODE for all of
+// the bits set and /MODE for any of the bits set. MODE is octal or symbolic.
+func parsePerm(value string) (Predicate, error) {
+	var match byte
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "/") {
+		match = value[0]
+		value = value[1:]
+	}
+
+	bits, err := ParseMode(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mode '%s' for '--perm'", value)
+	}
+
+	return func(_ fs.FS, path string, info fs.FileInfo) bool {
+		perm := permBits(info.Mode())
+		switch match {
+		case '-':
+			return perm&bits == bits
+		case '/':
+			// find treats /000 as matching everything
+			return bits == 0 || perm&bits != 0
+		default:
+			return perm == bits
+		}
+	}, nil
+}
+
+// permBits returns the permission and special bits of a mode in their
+// traditional octal positions
+func permBits(mode fs.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return bits
+}
+
+// ParseMode parses an octal mode such as 644 or a comma separated symbolic
+// mode such as u+rw,go=r, applied to an initial mode of zero
+func ParseMode(value string) (uint32, error) {
+	if value == "" {
+		return 0, fmt.Errorf("empty mode")
+	}
+	if value[0] >= '0' && value[0] <= '7' {
+		n, err := strconv.ParseUint(value, 8, 32)
+		if err != nil || n > 0o7777 {
+			return 0, fmt.Errorf("invalid octal mode '%s'", value)
+		}
+		return uint32(n), nil
+	}
+
+	var mode uint32
+	for _, clause := range strings.Split(value, ",") {
+		var who uint32
+		i := 0
+		for ; i < len(clause) && strings.IndexByte("ugoa", clause[i]) >= 0; i++ {
+			switch clause[i] {
+			case 'u':
+				who |= 0o4700
+			case 'g':
+				who |= 0o2070
+			case 'o':
+				who |= 0o1007
+			case 'a':
+				who |= 0o7777
+			}
+		}
+		if who == 0 {
+			who = 0o7777
+		}
+		if i == len(clause) || strings.IndexByte("+-=", clause[i]) < 0 {
+			return 0, fmt.Errorf("invalid symbolic mode '%s'", clause)
+		}
+		op := clause[i]
+
+		var perms uint32
+		for _, p := range clause[i+1:] {
+			switch p {
+			case 'r':
+				perms |= 0o444
+			case 'w':
+				perms |= 0o222
+			case 'x':
+				perms |= 0o111
+			case 's':
+				perms |= 0o6000
+			case 't':
+				perms |= 0o1000
+			default:
+				return 0, fmt.Errorf("invalid symbolic mode '%s'", clause)
+			}
+		}
+		perms &= who
+
+		switch op {
+		case '+':
+			mode |= perms
+		case '-':
+			mode &^= perms
+		case '=':
+			mode = mode&^who | perms
+		}
+	}
+
+	return mode, nil
+}
+
+// isEmpty handles --empty, matching empty regular files and directories
+func isEmpty(fsys fs.FS, path string, info fs.FileInfo) bool {
+	if info.IsDir() {
+		f, err := fsys.Open(path)
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+
+		// Reading a single entry is enough to tell
+		dir, ok := f.(fs.ReadDirFile)
+		if !ok {
+			return false
+		}
+		entries, _ := dir.ReadDir(1)
+		return len(entries) == 0
+	}
+	return info.Mode().IsRegular() && info.Size() == 0
+}
+
+// splitSign separates a leading + or - from a value
+func splitSign(value string) (byte, string) {
+	if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+		return value[0], value[1:]
+	}
+	return 0, value
+}
@@ -0,0 +1,217 @@
+package predicate
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// mockFileInfo implements fs.FileInfo for testing
+type mockFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (m mockFileInfo) Name() string       { return m.name }
+func (m mockFileInfo) Size() int64        { return m.size }
+func (m mockFileInfo) Mode() fs.FileMode  { return m.mode }
+func (m mockFileInfo) ModTime() time.Time { return m.modTime }
+func (m mockFileInfo) IsDir() bool        { return m.mode.IsDir() }
+func (m mockFileInfo) Sys() interface{}   { return nil }
+
+func TestParse(t *testing.T) {
+	fixedNow := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return fixedNow }
+	defer func() { now = time.Now }()
+
+	regular := mockFileInfo{name: "Main.go", size: 2048, mode: 0644, modTime: fixedNow.Add(-time.Hour)}
+	dir := mockFileInfo{name: "src", size: 4096, mode: fs.ModeDir | 0755, modTime: fixedNow.Add(-72 * time.Hour)}
+	link := mockFileInfo{name: "link", mode: fs.ModeSymlink | 0777}
+	pipe := mockFileInfo{name: "fifo", mode: fs.ModeNamedPipe | 0600}
+	socket := mockFileInfo{name: "sock", mode: fs.ModeSocket | 0755}
+	block := mockFileInfo{name: "sda", mode: fs.ModeDevice | 0660}
+	char := mockFileInfo{name: "tty", mode: fs.ModeDevice | fs.ModeCharDevice | 0620}
+	setuid := mockFileInfo{name: "passwd", mode: fs.ModeSetuid | 0755}
+	worldWritable := mockFileInfo{name: "tmp", mode: fs.ModeDir | fs.ModeSticky | 0777}
+
+	tests := []struct {
+		name     string
+		flag     string
+		value    string
+		info     fs.FileInfo
+		expected bool
+	}{
+		{"Type regular", "type", "f", regular, true},
+		{"Type regular not dir", "type", "f", dir, false},
+		{"Type dir", "type", "d", dir, true},
+		{"Type symlink", "type", "l", link, true},
+		{"Type pipe", "type", "p", pipe, true},
+		{"Type socket", "type", "s", socket, true},
+		{"Type block", "type", "b", block, true},
+		{"Type block not char", "type", "b", char, false},
+		{"Type char", "type", "c", char, true},
+		{"Type list", "type", "f,l", link, true},
+		{"Type list no match", "type", "f,l", dir, false},
+
+		{"Size larger", "size", "+1k", regular, true},
+		{"Size larger no match", "size", "+2k", regular, false},
+		{"Size smaller", "size", "-3k", regular, true},
+		{"Size exact", "size", "2048", regular, true},
+		{"Size exact bytes", "size", "2048c", regular, true},
+		{"Size megabytes", "size", "+1M", regular, false},
+
+		{"Newer than", "newer-than", "2h", regular, true},
+		{"Newer than no match", "newer-than", "2d", dir, false},
+		{"Older than", "older-than", "2d", dir, true},
+		{"Older than weeks", "older-than", "1w", dir, false},
+		{"Older than no match", "older-than", "30m", regular, true},
+
+		{"Name", "name", "*.go", regular, true},
+		{"Name is case sensitive", "name", "*.GO", regular, false},
+		{"Name case", "name", "Main.*", regular, true},
+		{"Iname", "iname", "main.GO", regular, true},
+
+		{"Perm exact octal", "perm", "644", regular, true},
+		{"Perm exact no match", "perm", "600", regular, false},
+		{"Perm exact symbolic", "perm", "u=rw,go=r", regular, true},
+		{"Perm any world writable", "perm", "/o+w", worldWritable, true},
+		{"Perm any world writable no match", "perm", "/o+w", regular, false},
+		{"Perm all bits", "perm", "-444", regular, true},
+		{"Perm all bits no match", "perm", "-111", regular, false},
+		{"Perm setuid", "perm", "/4000", setuid, true},
+		{"Perm setuid symbolic", "perm", "/u+s", setuid, true},
+		{"Perm sticky", "perm", "-1777", worldWritable, true},
+		{"Perm any zero", "perm", "/000", regular, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.flag, tt.value)
+			if err != nil {
+				t.Fatalf("Parse(%q, %q) error = %v", tt.flag, tt.value, err)
+			}
+			if got := p(nil, "", tt.info); got != tt.expected {
+				t.Errorf("--%s=%s on %s = %v, want %v", tt.flag, tt.value, tt.info.Name(), got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		flag  string
+		value string
+	}{
+		{"type", "x"},
+		{"type", ""},
+		{"size", "+abc"},
+		{"size", "10Q"},
+		{"size", ""},
+		{"size", "+99999999999T"},
+		{"newer-than", "2y"},
+		{"newer-than", "99999999999w"},
+		{"newer-than", ""},
+		{"older-than", "xd"},
+		{"name", "[a"},
+		{"perm", "999"},
+		{"perm", "q+w"},
+		{"perm", "u+z"},
+		{"perm", ""},
+		{"empty", "yes"},
+		{"bogus", "1"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse(tt.flag, tt.value); err == nil {
+			t.Errorf("Parse(%q, %q) expected an error", tt.flag, tt.value)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected uint32
+	}{
+		{"0", 0},
+		{"755", 0o755},
+		{"4755", 0o4755},
+		{"u+x", 0o100},
+		{"a+r", 0o444},
+		{"+w", 0o222},
+		{"u=rwx,g=rx,o=rx", 0o755},
+		{"a+rwx,o-w", 0o775},
+		{"u+s", 0o4000},
+		{"g+s", 0o2000},
+		{"o+t", 0o1000},
+		{"ug+w", 0o220},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMode(tt.value)
+		if err != nil || got != tt.expected {
+			t.Errorf("ParseMode(%q) = %o, %v, want %o", tt.value, got, err, tt.expected)
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.Mkdir(tmpDir+"/empty", 0755)
+	_ = os.Mkdir(tmpDir+"/full", 0755)
+	_ = os.WriteFile(tmpDir+"/full/file", []byte("data"), 0644)
+	_ = os.WriteFile(tmpDir+"/zero", nil, 0644)
+
+	p, err := Parse("empty", "")
+	if err != nil {
+		t.Fatalf("Parse(empty) error = %v", err)
+	}
+
+	fsys := os.DirFS(tmpDir)
+	for name, expected := range map[string]bool{"empty": true, "full": false, "zero": true, "full/file": false} {
+		info, _ := os.Lstat(tmpDir + "/" + name)
+		if got := p(fsys, name, info); got != expected {
+			t.Errorf("--empty on %s = %v, want %v", name, got, expected)
+		}
+	}
+}
+
+func TestEmptyFS(t *testing.T) {
+	// Directories of an archive or git tree only exist in fsys
+	fsys := fstest.MapFS{
+		"s/emptydir":  {Mode: fs.ModeDir | 0755},
+		"s/full/file": {Data: []byte("data")},
+		"s/zero":      {},
+	}
+	p, _ := Parse("empty", "")
+
+	for name, expected := range map[string]bool{"s/emptydir": true, "s/full": false, "s/zero": true, "s/full/file": false, "s/missing": false} {
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			info = mockFileInfo{name: name, mode: fs.ModeDir}
+		}
+		if got := p(fsys, name, info); got != expected {
+			t.Errorf("--empty on %s = %v, want %v", name, got, expected)
+		}
+	}
+}
+
+func TestMatchAll(t *testing.T) {
+	info := mockFileInfo{name: "a.go", size: 10, mode: 0644}
+	isGo, _ := Parse("name", "*.go")
+	isBig, _ := Parse("size", "+100")
+
+	if !MatchAll(nil, nil, "", info) {
+		t.Errorf("MatchAll() with no predicates = false, want true")
+	}
+	if !MatchAll([]Predicate{isGo}, nil, "", info) {
+		t.Errorf("MatchAll() with matching predicate = false, want true")
+	}
+	if MatchAll([]Predicate{isGo, isBig}, nil, "", info) {
+		t.Errorf("MatchAll() with one failing predicate = true, want false")
+	}
+}
@@ -0,0 +1,212 @@
+// Package quoting makes file names safe to print, following the quoting
+// styles of GNU ls. Names holding control characters or shell
+// metacharacters can be shown escaped, quoted for pasting into a shell, or
+// with unprintable characters replaced by question marks.
+package quoting
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Style is a way of quoting names
+type Style int
+
+const (
+	// Literal prints names as they are
+	Literal Style = iota
+	// Shell quotes names that a shell would not read back as they are
+	Shell
+	// ShellAlways quotes every name for the shell
+	ShellAlways
+	// ShellEscape quotes names like Shell and writes unprintable
+	// characters as $'\n' escapes
+	ShellEscape
+	// C writes names as C strings, quoted and with backslash escapes
+	C
+	// Escape writes backslash escapes like C, without the quotes
+	Escape
+	// Locale writes backslash escapes inside typographic quotes
+	Locale
+)
+
+// Names lists the name of each style as given to --quoting-style
+var Names = []string{"literal", "shell", "shell-always", "shell-escape", "c", "escape", "locale"}
+
+// ParseStyle returns the style with the given name
+func ParseStyle(name string) (Style, error) {
+	for i, n := range Names {
+		if n == name {
+			return Style(i), nil
+		}
+	}
+	return Literal, fmt.Errorf("invalid quoting style '%s'", name)
+}
+
+// String returns the name of the style
+func (s Style) String() string {
+	if s < 0 || int(s) >= len(Names) {
+		return fmt.Sprintf("Style(%d)", int(s))
+	}
+	return Names[s]
+}
+
+// shellSpecial holds the characters that make the shell styles quote a name
+const shellSpecial = "\t\n !\"$&'()*;<>?[\\]^`{|}"
+
+// Quote returns a name as printed in a style. With hideControl, the literal
+// and shell styles replace unprintable characters with ?, as ls -q does.
+func Quote(name string, style Style, hideControl bool) string {
+	switch style {
+	case Shell, ShellAlways:
+		return shellQuote(name, style == ShellAlways, false, hideControl)
+	case ShellEscape:
+		return shellQuote(name, false, true, hideControl)
+	case C:
+		return `"` + backslashEscape(name, '"', false) + `"`
+	case Escape:
+		return backslashEscape(name, 0, true)
+	case Locale:
+		return "‘" + backslashEscape(name, 0, false) + "’"
+	}
+
+	if hideControl {
+		return hideUnprintable(name)
+	}
+	return name
+}
+
+// NeedsQuotes reports whether a style may wrap names in quotes, which
+// leaves room before unquoted names so all names line up
+func (s Style) NeedsQuotes() bool {
+	return s == Shell || s == ShellEscape
+}
+
+// printable reports whether a rune decoded from a name is shown as it is
+func printable(r rune, size int) bool {
+	return !(r == utf8.RuneError && size <= 1) && unicode.IsPrint(r)
+}
+
+// hideUnprintable replaces every unprintable character or invalid byte
+// with a question mark
+func hideUnprintable(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		if printable(r, size) {
+			b.WriteString(name[i : i+size])
+		} else {
+			b.WriteByte('?')
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// shellQuote quotes a name for the shell when it has to be, or always.
+// Single quotes are used unless the name holds a single quote and nothing
+// special inside double quotes. With escape, unprintable characters are
+// written as $'...' sections between quoted runs.
+func shellQuote(name string, always, escape, hideControl bool) string {
+	if name == "" {
+		return "''"
+	}
+
+	quote := always
+	unprintable := false
+	for i, r := range name {
+		_, size := utf8.DecodeRuneInString(name[i:])
+		switch {
+		case !printable(r, size):
+			unprintable = true
+		case strings.ContainsRune(shellSpecial, r), i == 0 && (r == '#' || r == '~'):
+			quote = true
+		}
+	}
+
+	if unprintable {
+		if escape {
+			return shellEscape(name)
+		}
+		if hideControl {
+			name = hideUnprintable(name)
+		}
+		quote = true
+	}
+	if !quote {
+		return name
+	}
+
+	if strings.Contains(name, "'") && !strings.ContainsAny(name, "$`\\!\"") {
+		return `"` + name + `"`
+	}
+	return "'" + strings.ReplaceAll(name, "'", `'\''`) + "'"
+}
+
+// shellEscape quotes runs of printable characters in single quotes and
+// writes the rest as $'...' escapes between them. Like GNU ls, the result
+// always opens with a single quote.
+func shellEscape(name string) string {
+	var b strings.Builder
+	if r, size := utf8.DecodeRuneInString(name); !printable(r, size) {
+		b.WriteString("''")
+	}
+	for i := 0; i < len(name); {
+		// Find the run of characters that are all printable, or all not
+		start := i
+		r, size := utf8.DecodeRuneInString(name[i:])
+		isPrintable := printable(r, size)
+		for i < len(name) {
+			r, size = utf8.DecodeRuneInString(name[i:])
+			if printable(r, size) != isPrintable {
+				break
+			}
+			i += size
+		}
+
+		run := name[start:i]
+		if isPrintable {
+			b.WriteString("'" + strings.ReplaceAll(run, "'", `'\''`) + "'")
+		} else {
+			b.WriteString("$'" + backslashEscape(run, '\'', false) + "'")
+		}
+	}
+	return b.String()
+}
+
+// controlEscapes holds the short backslash escapes of control characters
+var controlEscapes = map[byte]string{
+	'\a': `\a`, '\b': `\b`, '\f': `\f`, '\n': `\n`, '\r': `\r`, '\t': `\t`, '\v': `\v`,
+}
+
+// backslashEscape escapes backslashes, the quote character if any, and
+// unprintable characters, writing bytes without a short escape in octal.
+// With escapeSpace, spaces are escaped too.
+func backslashEscape(name string, quote byte, escapeSpace bool) string {
+	var b strings.Builder
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case quote != 0 && r == rune(quote):
+			b.WriteString(`\` + string(quote))
+		case escapeSpace && r == ' ':
+			b.WriteString(`\ `)
+		case printable(r, size):
+			b.WriteString(name[i : i+size])
+		default:
+			for _, c := range []byte(name[i : i+size]) {
+				if esc, ok := controlEscapes[c]; ok {
+					b.WriteString(esc)
+				} else {
+					fmt.Fprintf(&b, `\%03o`, c)
+				}
+			}
+		}
+		i += size
+	}
+	return b.String()
+}
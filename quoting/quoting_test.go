@@ -0,0 +1,55 @@
+package quoting
+
+import "testing"
+
+func TestQuote(t *testing.T) {
+	names := []string{"plain", "a b", "a\nb", "it's", "x$y", "q'$", "#c", "d#", "\033[31m", "é", "back\\slash", ""}
+	tests := []struct {
+		style       Style
+		hideControl bool
+		want        []string
+	}{
+		{Literal, false, []string{"plain", "a b", "a\nb", "it's", "x$y", "q'$", "#c", "d#", "\033[31m", "é", "back\\slash", ""}},
+		{Literal, true, []string{"plain", "a b", "a?b", "it's", "x$y", "q'$", "#c", "d#", "?[31m", "é", "back\\slash", ""}},
+		{Shell, false, []string{"plain", "'a b'", "'a\nb'", `"it's"`, "'x$y'", `'q'\''$'`, "'#c'", "d#", "'\033[31m'", "é", `'back\slash'`, "''"}},
+		{Shell, true, []string{"plain", "'a b'", "'a?b'", `"it's"`, "'x$y'", `'q'\''$'`, "'#c'", "d#", "'?[31m'", "é", `'back\slash'`, "''"}},
+		{ShellAlways, false, []string{"'plain'", "'a b'", "'a\nb'", `"it's"`, "'x$y'", `'q'\''$'`, "'#c'", "'d#'", "'\033[31m'", "'é'", `'back\slash'`, "''"}},
+		{ShellEscape, false, []string{"plain", "'a b'", `'a'$'\n''b'`, `"it's"`, "'x$y'", `'q'\''$'`, "'#c'", "d#", `''$'\033''[31m'`, "é", `'back\slash'`, "''"}},
+		{C, false, []string{`"plain"`, `"a b"`, `"a\nb"`, `"it's"`, `"x$y"`, `"q'$"`, `"#c"`, `"d#"`, `"\033[31m"`, `"é"`, `"back\\slash"`, `""`}},
+		{Escape, true, []string{"plain", `a\ b`, `a\nb`, "it's", "x$y", "q'$", "#c", "d#", `\033[31m`, "é", `back\\slash`, ""}},
+		{Locale, false, []string{"‘plain’", "‘a b’", `‘a\nb’`, "‘it's’", "‘x$y’", "‘q'$’", "‘#c’", "‘d#’", `‘\033[31m’`, "‘é’", `‘back\\slash’`, "‘’"}},
+	}
+
+	for _, tt := range tests {
+		for i, name := range names {
+			if got := Quote(name, tt.style, tt.hideControl); got != tt.want[i] {
+				t.Errorf("Quote(%q, %s, %v) = %q, want %q", name, tt.style, tt.hideControl, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestQuoteInvalidUTF8(t *testing.T) {
+	tests := map[Style]string{
+		Literal:     "?x",
+		ShellEscape: `''$'\377''x'`,
+		C:           `"\377x"`,
+	}
+	for style, want := range tests {
+		if got := Quote("\xffx", style, true); got != want {
+			t.Errorf("Quote(%q, %s) = %q, want %q", "\xffx", style, got, want)
+		}
+	}
+}
+
+func TestParseStyle(t *testing.T) {
+	for i, name := range Names {
+		style, err := ParseStyle(name)
+		if err != nil || style != Style(i) || style.String() != name {
+			t.Errorf("ParseStyle(%s) = %v, %v", name, style, err)
+		}
+	}
+	if _, err := ParseStyle("clever"); err == nil {
+		t.Error("ParseStyle(clever) succeeded, want an error")
+	}
+}
@@ -0,0 +1,15 @@
+package sorting
+
+import "io/fs"
+
+// SortSize sorts files by size in descending order, keeping the existing
+// order of files of the same size
+func SortSize(files []fs.FileInfo) {
+	for i := 0; i < len(files); i++ {
+		for j := 0; j < len(files)-i-1; j++ {
+			if files[j].Size() < files[j+1].Size() {
+				files[j], files[j+1] = files[j+1], files[j]
+			}
+		}
+	}
+}
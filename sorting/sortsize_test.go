@@ -0,0 +1,57 @@
+package sorting
+
+import (
+	"io/fs"
+	"testing"
+)
+
+// sizedFileInfo is a mockFileInfo with a size
+type sizedFileInfo struct {
+	mockFileInfo
+	size int64
+}
+
+func (s sizedFileInfo) Size() int64 { return s.size }
+
+func TestSortSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []fs.FileInfo
+		expected []string
+	}{
+		{
+			name: "largest first",
+			files: []fs.FileInfo{
+				sizedFileInfo{mockFileInfo{name: "small"}, 10},
+				sizedFileInfo{mockFileInfo{name: "large"}, 3000},
+				sizedFileInfo{mockFileInfo{name: "medium"}, 200},
+			},
+			expected: []string{"large", "medium", "small"},
+		},
+		{
+			name: "same size keeps order",
+			files: []fs.FileInfo{
+				sizedFileInfo{mockFileInfo{name: "b"}, 5},
+				sizedFileInfo{mockFileInfo{name: "a"}, 5},
+				sizedFileInfo{mockFileInfo{name: "c"}, 7},
+			},
+			expected: []string{"c", "b", "a"},
+		},
+		{
+			name:     "empty list",
+			files:    []fs.FileInfo{},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SortSize(tt.files)
+			for i, file := range tt.files {
+				if file.Name() != tt.expected[i] {
+					t.Errorf("SortSize() position %d = %s, want %s", i, file.Name(), tt.expected[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Package xattr reads the extended attributes of files
+package xattr
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// initialSize is the buffer size first tried for a value, which fits most
+const initialSize = 256
+
+// Get returns the value of an extended attribute of a file, following
+// symlinks. The buffer grows until the value fits, since it may change
+// size between asking for its size and reading it.
+func Get(path, name string) ([]byte, error) {
+	return get(path, name, syscall.Getxattr)
+}
+
+// LGet returns the value of an extended attribute of a file, or of a
+// symlink itself rather than its target
+func LGet(path, name string) ([]byte, error) {
+	return get(path, name, lgetxattr)
+}
+
+// get reads an attribute with a getxattr system call
+func get(path, name string, getxattr func(path, name string, dest []byte) (int, error)) ([]byte, error) {
+	size := initialSize
+	for {
+		buf := make([]byte, size)
+		n, err := getxattr(path, name, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			need, err := getxattr(path, name, nil)
+			if err != nil {
+				return nil, err
+			}
+			size = max(need, size*2)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// List returns the names of the extended attributes of a file, or of a
+// symlink itself rather than its target. The buffer grows until the names
+// fit, as for Get.
+func List(path string) ([]string, error) {
+	size := initialSize
+	for {
+		buf := make([]byte, size)
+		n, err := llistxattr(path, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			need, err := llistxattr(path, nil)
+			if err != nil {
+				return nil, err
+			}
+			size = max(need, size*2)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Names are each terminated by a NUL byte
+		var names []string
+		for _, name := range strings.Split(string(buf[:n]), "\x00") {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+}
+
+// IsMissing reports whether an error means that a file has no such
+// attribute, or that its file system does not support attributes at all
+func IsMissing(err error) bool {
+	return errors.Is(err, syscall.ENODATA) || errors.Is(err, syscall.ENOTSUP)
+}
+
+// lgetxattr calls lgetxattr(2), which the syscall package lacks
+func lgetxattr(path, name string, dest []byte) (int, error) {
+	pathPtr, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	namePtr, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+
+	n, _, errno := syscall.Syscall6(syscall.SYS_LGETXATTR, uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(namePtr)), uintptr(bufferPtr(dest)), uintptr(len(dest)), 0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// llistxattr calls llistxattr(2), which the syscall package lacks
+func llistxattr(path string, dest []byte) (int, error) {
+	pathPtr, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	n, _, errno := syscall.Syscall(syscall.SYS_LLISTXATTR, uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(bufferPtr(dest)), uintptr(len(dest)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// bufferPtr returns the address of a buffer, or nil for an empty one,
+// which asks the kernel for the size needed
+func bufferPtr(buf []byte) unsafe.Pointer {
+	if len(buf) == 0 {
+		return nil
+	}
+	return unsafe.Pointer(&buf[0])
+}
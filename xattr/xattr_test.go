@@ -0,0 +1,81 @@
+package xattr
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A value larger than the first buffer makes it grow
+	value := bytes.Repeat([]byte("v"), initialSize*3+1)
+	if err := syscall.Setxattr(path, "user.big", value, 0); err != nil {
+		t.Skipf("Cannot set an attribute: %v", err)
+	}
+	got, err := Get(path, "user.big")
+	if err != nil || !bytes.Equal(got, value) {
+		t.Errorf("Get() = %d bytes, %v, want %d bytes", len(got), err, len(value))
+	}
+
+	if _, err := Get(path, "user.missing"); !IsMissing(err) {
+		t.Errorf("Get() of a missing attribute error = %v, want a missing error", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Enough names to outgrow the first buffer
+	var want []string
+	for i := 0; i < 40; i++ {
+		name := fmt.Sprintf("user.attribute.number.%02d", i)
+		if err := syscall.Setxattr(path, name, []byte{byte(i)}, 0); err != nil {
+			t.Skipf("Cannot set an attribute: %v", err)
+		}
+		want = append(want, name)
+	}
+
+	names, err := List(path)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	var got []string
+	for _, name := range names {
+		if strings.HasPrefix(name, "user.") {
+			got = append(got, name)
+		}
+	}
+	sort.Strings(got)
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+
+	// A symlink is listed itself, not its target
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("file", link); err != nil {
+		t.Fatal(err)
+	}
+	names, err = List(link)
+	if err != nil {
+		t.Fatalf("List() of a symlink error = %v", err)
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, "user.") {
+			t.Errorf("List() of a symlink returned %s of its target", name)
+		}
+	}
+}